@@ -0,0 +1,57 @@
+package device
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The I2C_TIMEOUT ioctl counts in units of 10ms.
+const adapterTimeoutUnit = 10 * time.Millisecond
+
+// AdapterTimeout returns how long the adapter of bus number bus waits for a
+// transfer before giving up, as set with the I2C_TIMEOUT ioctl.
+//
+// The ioctl can only set the value, so it is read from the adapter's timeout
+// attribute in sysfs, in the ioctl's units of 10ms. Mainline kernels don't
+// export it, only some vendor drivers do; elsewhere this returns
+// ErrUnsupported.
+func AdapterTimeout(bus int) (time.Duration, error) {
+	timeout, err := readAdapterAttr(bus, "timeout")
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(timeout) * adapterTimeoutUnit, nil
+}
+
+// AdapterRetries returns how many times the adapter of bus number bus retries
+// a transfer that lost arbitration, as set with the I2C_RETRIES ioctl. Like
+// AdapterTimeout it relies on a sysfs attribute mainline kernels don't export
+// and returns ErrUnsupported without it.
+func AdapterRetries(bus int) (int, error) {
+	return readAdapterAttr(bus, "retries")
+}
+
+func readAdapterAttr(bus int, name string) (int, error) {
+	dir := fmt.Sprintf("/sys/class/i2c-adapter/i2c-%d", bus)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return 0, ErrBusNotFound
+	}
+
+	data, err := os.ReadFile(dir + "/" + name)
+	if os.IsNotExist(err) {
+		return 0, ErrUnsupported
+	} else if err != nil {
+		return 0, err
+	}
+
+	value, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("Parsing i2c-%d %s: %w", bus, name, err)
+	}
+
+	return value, nil
+}
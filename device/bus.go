@@ -0,0 +1,255 @@
+package device
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/gocql/gocql"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// Bus is a single open /dev/i2c-N shared by several devices. Devices obtained
+// from a Bus use its file descriptor and take turns on it through the bus
+// mutex, selecting their own address at the start of every transaction.
+type Bus struct {
+	mu     sync.Mutex
+	rc     *os.File
+	closed bool
+}
+
+// Opens an i2c bus for sharing between devices.
+func OpenBus(bus int) (*Bus, error) {
+	f, err := openBusFile(bus, os.O_RDWR)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bus{rc: f}, nil
+}
+
+// Device returns a handle for the device at addr on the bus. The handle stays
+// usable until it or the bus is closed.
+func (bus *Bus) Device(addr uint8, opts ...Option) (*I2C, error) {
+	if addr > 0x7F {
+		return nil, ErrInvalidAddress
+	}
+
+	device, err := bus.device(addr, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := device.verifyPresence(); err != nil {
+		return nil, err
+	}
+	if err := device.runInitHooks(); err != nil {
+		return nil, err
+	}
+
+	return device, nil
+}
+
+func (bus *Bus) device(addr uint8, opts []Option) (*I2C, error) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	if bus.closed {
+		return nil, ErrBusClosed
+	}
+	if err := selectAddress(bus.rc, addr); err != nil {
+		return nil, err
+	}
+
+	device := newI2C(bus.rc, addr, &bus.mu, opts)
+	device.bus = bus
+	return device, nil
+}
+
+// Close waits for in-flight transactions to finish and then closes the bus.
+// Any later operation on a device obtained from the bus returns ErrBusClosed.
+func (bus *Bus) Close() error {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	if bus.closed {
+		return ErrBusClosed
+	}
+
+	bus.closed = true
+	return bus.rc.Close()
+}
+
+// Range of addresses i2cdetect probes by default. The ones outside it are
+// reserved by the i2c specification.
+const (
+	firstScanAddress = 0x03
+	lastScanAddress  = 0x77
+)
+
+// What a bus scan found at an address.
+type ScanStatus int
+
+const (
+	// Nothing acknowledged the address.
+	ScanEmpty ScanStatus = iota
+	// A device acknowledged the probe.
+	ScanPresent
+	// A kernel driver has claimed the address, shown as UU by i2cdetect.
+	ScanBusy
+)
+
+func (s ScanStatus) String() string {
+	switch s {
+	case ScanEmpty:
+		return "empty"
+	case ScanPresent:
+		return "present"
+	case ScanBusy:
+		return "busy"
+	}
+
+	return "unknown"
+}
+
+type ScanResult struct {
+	Addr   uint8
+	Status ScanStatus
+}
+
+// Probes every address on i2c bus number bus that isn't reserved, from 0x03
+// to 0x77, and reports what it found at each of them in address order.
+func ScanBus(bus int) ([]ScanResult, error) {
+	b, err := OpenBus(bus)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Close()
+
+	return b.scan()
+}
+
+func (bus *Bus) scan() ([]ScanResult, error) {
+	results := []ScanResult{}
+	for addr := firstScanAddress; addr <= lastScanAddress; addr++ {
+		status := ScanEmpty
+		device, err := bus.device(uint8(addr), nil)
+		switch {
+		case err == ErrBusClosed:
+			return results, err
+		case errors.Is(err, syscall.EBUSY):
+			status = ScanBusy
+		case err == nil && device.Present():
+			status = ScanPresent
+		}
+
+		results = append(results, ScanResult{uint8(addr), status})
+	}
+
+	return results, nil
+}
+
+// Scans bus number bus and reads the UUID of every device on it, returning
+// the addresses found for each UUID. Any UUID with more than one address is
+// shared by several devices. Devices that report the nil UUID haven't been
+// provisioned and are left out.
+func CheckDuplicateUUIDs(bus int) (map[[16]byte][]uint8, error) {
+	b, err := OpenBus(bus)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Close()
+
+	results, err := b.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	uuids := map[[16]byte][]uint8{}
+	for _, result := range results {
+		if result.Status != ScanPresent {
+			continue
+		}
+
+		addr := result.Addr
+		device, err := b.Device(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		uuid, err := device.UUID()
+		if err != nil {
+			return nil, fmt.Errorf("Reading UUID at 0x%02x: %w", addr, err)
+		}
+		if uuid == (gocql.UUID{}) {
+			continue
+		}
+
+		uuids[uuid] = append(uuids[uuid], addr)
+	}
+
+	return uuids, nil
+}
+
+// Scans bus number bus and opens every device whose UUID starts with prefix,
+// for fleets that encode the type of a device in its UUID. UUIDs are read
+// without any options, so init hooks and the like only run on the matching
+// devices, which are then opened with New and opts. The returned devices are
+// the caller's to close. If any of them fails to open, the ones opened so
+// far are closed and the error is returned.
+func OpenByUUIDPrefix(bus int, prefix []byte, opts ...Option) ([]*I2C, error) {
+	addrs, err := matchUUIDPrefix(bus, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []*I2C{}
+	for _, addr := range addrs {
+		device, err := New(addr, bus, opts...)
+		if err != nil {
+			for _, match := range matches {
+				match.Close()
+			}
+			return nil, fmt.Errorf("Opening device at 0x%02x: %w", addr, err)
+		}
+
+		matches = append(matches, device)
+	}
+
+	return matches, nil
+}
+
+// Returns the addresses on bus number bus of the devices whose UUID starts
+// with prefix.
+func matchUUIDPrefix(bus int, prefix []byte) ([]uint8, error) {
+	b, err := OpenBus(bus)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Close()
+
+	results, err := b.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := []uint8{}
+	for _, result := range results {
+		if result.Status != ScanPresent {
+			continue
+		}
+
+		device, err := b.Device(result.Addr)
+		if err != nil {
+			return nil, err
+		}
+
+		uuid, err := device.UUID()
+		if err != nil {
+			return nil, fmt.Errorf("Reading UUID at 0x%02x: %w", result.Addr, err)
+		}
+		if bytes.HasPrefix(uuid[:], prefix) {
+			addrs = append(addrs, result.Addr)
+		}
+	}
+
+	return addrs, nil
+}
@@ -0,0 +1,29 @@
+package device
+
+import (
+	"testing"
+)
+
+func TestBusDeviceClose(t *testing.T) {
+	fake, peer := newFakeDevice(t)
+	bus := &Bus{rc: fake.rc}
+	device := newI2C(bus.rc, 0x48, &bus.mu, nil)
+	device.bus = bus
+
+	if err := device.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := device.Close(); err != ErrClosed {
+		t.Errorf("Second Close = %v, want ErrClosed", err)
+	}
+	if _, err := device.ReadRegister(0x10); err != ErrClosed {
+		t.Errorf("ReadRegister after Close = %v, want ErrClosed", err)
+	}
+
+	// The bus descriptor stays open for the other devices on it.
+	reply(t, peer, 0x12)
+	var buf [1]byte
+	if _, err := bus.rc.Read(buf[:]); err != nil {
+		t.Errorf("Bus descriptor was closed with the device: %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+package device
+
+import (
+	"github.com/gocql/gocql"
+	"io"
+)
+
+// Conn is the part of I2C that code talking to a device usually needs, so a
+// wrapper such as FaultInjector or a fake can stand in for the real device.
+type Conn interface {
+	io.ReadWriteCloser
+	Addr() uint8
+	ReadRegister(reg byte) ([]byte, error)
+	WriteRegister(reg byte, data []byte) error
+	SequentialRead(startReg byte, n int) ([]byte, error)
+	WriteRead(w []byte, r []byte) error
+	UUID() (gocql.UUID, error)
+}
+
+var _ Conn = (*I2C)(nil)
@@ -0,0 +1,199 @@
+package device
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Reads a two byte register and interprets it as a big endian Q-format fixed
+// point number with intBits integer bits and fracBits fractional bits, such as
+// Q8.8. When signed is set the value is two's complement and the sign bit
+// counts towards intBits. If the format is narrower than 16 bits only the low
+// intBits+fracBits bits of the register are used.
+func (device *I2C) ReadFixed(reg byte, intBits, fracBits int, signed bool) (float64, error) {
+	width := intBits + fracBits
+	if intBits < 0 || fracBits < 0 || width < 1 || width > 16 {
+		return 0, errors.New("Invalid fixed point format")
+	}
+
+	word, err := device.readUint16(reg, binary.BigEndian)
+	if err != nil {
+		return 0, err
+	}
+
+	return decodeFixed(word, intBits, fracBits, signed), nil
+}
+
+// Decodes word as described for ReadFixed. The format has to be valid.
+func decodeFixed(word uint16, intBits, fracBits int, signed bool) float64 {
+	width := intBits + fracBits
+	raw := uint32(word) & (1<<uint(width) - 1)
+	value := int32(raw)
+	if signed && raw&(1<<uint(width-1)) != 0 {
+		value -= 1 << uint(width)
+	}
+
+	return math.Ldexp(float64(value), -fracBits)
+}
+
+// Reads the one byte status register statusReg and reports whether its write
+// protect bit is set, so callers can bail out before a long write that would
+// only fail.
+func (device *I2C) WriteProtected(statusReg byte, bit uint) (bool, error) {
+	if bit > 7 {
+		return false, errors.New("Status bit out of range")
+	}
+
+	status := getBuffer(1)
+	defer putBuffer(status)
+
+	err := device.transact(func() error {
+		return device.readRegisterFull(statusReg, *status, nil)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return (*status)[0]&(1<<bit) != 0, nil
+}
+
+// Reads the manufacturer and device IDs many parts expose in two registers.
+// Each register is read as a big endian word, so a register returning the
+// bytes 0x10 0x4A gives 0x104A.
+func (device *I2C) ReadDeviceID(mfgReg, devReg byte) (mfg uint16, dev uint16, err error) {
+	mfg, err = device.readUint16(mfgReg, binary.BigEndian)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	dev, err = device.readUint16(devReg, binary.BigEndian)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return mfg, dev, nil
+}
+
+var ErrOutOfRange = errors.New("Value doesn't fit in the register")
+
+// Reads a three byte big endian register.
+func (device *I2C) ReadUint24BE(reg byte) (uint32, error) {
+	buf := getBuffer(3)
+	defer putBuffer(buf)
+
+	err := device.transact(func() error {
+		_, err := device.sequentialReadInto(reg, *buf)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	b := *buf
+	return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2]), nil
+}
+
+// Reads a three byte big endian two's complement register, sign extending it
+// to 32 bits.
+func (device *I2C) ReadInt24BE(reg byte) (int32, error) {
+	raw, err := device.ReadUint24BE(reg)
+	if err != nil {
+		return 0, err
+	}
+
+	return int32(raw<<8) >> 8, nil
+}
+
+// Writes v to a three byte big endian register.
+func (device *I2C) WriteUint24BE(reg byte, v uint32) error {
+	if v > 0xFFFFFF {
+		return ErrOutOfRange
+	}
+
+	return device.WriteRegister(reg, []byte{byte(v >> 16), byte(v >> 8), byte(v)})
+}
+
+// Writes v to a three byte big endian two's complement register.
+func (device *I2C) WriteInt24BE(reg byte, v int32) error {
+	if v < -1<<23 || v >= 1<<23 {
+		return ErrOutOfRange
+	}
+
+	return device.WriteUint24BE(reg, uint32(v)&0xFFFFFF)
+}
+
+// Reads a two byte register in the device's byte order, big endian unless set
+// with WithByteOrder.
+func (device *I2C) ReadUint16(reg byte) (uint16, error) {
+	return device.readUint16(reg, device.byteOrder)
+}
+
+// Reads a two byte two's complement register in the device's byte order.
+func (device *I2C) ReadInt16(reg byte) (int16, error) {
+	raw, err := device.readUint16(reg, device.byteOrder)
+	return int16(raw), err
+}
+
+// Writes v to a two byte register in the device's byte order.
+func (device *I2C) WriteUint16(reg byte, v uint16) error {
+	buf := make([]byte, 2)
+	device.byteOrder.PutUint16(buf, v)
+	return device.WriteRegister(reg, buf)
+}
+
+// Reads count consecutive two byte registers starting at startReg in a single
+// sequential read and decodes them in the given byte order. A short read is
+// an error, including one that ends halfway through a word.
+func (device *I2C) ReadWords(startReg byte, count int, order binary.ByteOrder) ([]uint16, error) {
+	if count < 0 {
+		return nil, errors.New("Negative word count")
+	}
+
+	buf := getBuffer(count * 2)
+	defer putBuffer(buf)
+
+	err := device.transact(func() error {
+		_, err := device.sequentialReadInto(startReg, *buf)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	words := make([]uint16, count)
+	for i := range words {
+		words[i] = order.Uint16((*buf)[2*i:])
+	}
+
+	return words, nil
+}
+
+// Reads a two byte register in the device's byte order and applies a linear
+// calibration, returning raw*scale+offset. The raw value is two's complement
+// when signed is set and unsigned otherwise.
+func (device *I2C) ReadCalibrated(reg byte, scale, offset float64, signed bool) (float64, error) {
+	raw, err := device.ReadUint16(reg)
+	if err != nil {
+		return 0, err
+	}
+
+	value := float64(raw)
+	if signed {
+		value = float64(int16(raw))
+	}
+
+	return value*scale + offset, nil
+}
+
+// Reads a two byte register in the device's byte order and hands the raw
+// value to transform, for calibrations that aren't linear. Unsigned
+// registers can be recovered with uint16(raw).
+func (device *I2C) ReadTransformed(reg byte, transform func(raw int16) float64) (float64, error) {
+	raw, err := device.ReadInt16(reg)
+	if err != nil {
+		return 0, err
+	}
+
+	return transform(raw), nil
+}
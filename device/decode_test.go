@@ -0,0 +1,155 @@
+package device
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeFixed(t *testing.T) {
+	tests := []struct {
+		name     string
+		word     uint16
+		intBits  int
+		fracBits int
+		signed   bool
+		want     float64
+	}{
+		{"Q8.8 one", 0x0100, 8, 8, false, 1},
+		{"Q8.8 fraction", 0x1980, 8, 8, false, 25.5},
+		{"Q8.8 smallest step", 0x0001, 8, 8, false, 1.0 / 256},
+		{"Q8.8 unsigned max", 0xFFFF, 8, 8, false, 255 + 255.0/256},
+		{"Q8.8 signed positive", 0x7F80, 8, 8, true, 127.5},
+		{"Q8.8 signed minus one", 0xFF00, 8, 8, true, -1},
+		{"Q8.8 signed minus half", 0xFF80, 8, 8, true, -0.5},
+		{"Q8.8 signed most negative", 0x8000, 8, 8, true, -128},
+		{"Q1.15 half", 0x4000, 1, 15, true, 0.5},
+		{"Q1.15 minus one", 0x8000, 1, 15, true, -1},
+		{"Q1.15 minus a quarter", 0xE000, 1, 15, true, -0.25},
+		{"Q1.15 largest", 0x7FFF, 1, 15, true, 1 - 1.0/32768},
+		{"Q1.15 unsigned", 0x8000, 1, 15, false, 1},
+		{"Q4.4 ignores high bits", 0xAB18, 4, 4, false, 1.5},
+		{"Q4.4 signed negative", 0x00F8, 4, 4, true, -0.5},
+		{"Q16.0 signed", 0xFFFE, 16, 0, true, -2},
+	}
+
+	for _, test := range tests {
+		got := decodeFixed(test.word, test.intBits, test.fracBits, test.signed)
+		if got != test.want {
+			t.Errorf("%s: decodeFixed(0x%04x, %d, %d, %v) = %v, want %v", test.name,
+				test.word, test.intBits, test.fracBits, test.signed, got, test.want)
+		}
+	}
+}
+
+func TestReadInt24BE(t *testing.T) {
+	tests := []struct {
+		raw  []byte
+		want int32
+	}{
+		{[]byte{0x00, 0x00, 0x01}, 1},
+		{[]byte{0x7F, 0xFF, 0xFF}, 1<<23 - 1},
+		{[]byte{0x80, 0x00, 0x00}, -1 << 23},
+		{[]byte{0xFF, 0xFF, 0xFF}, -1},
+		{[]byte{0xFF, 0xFF, 0xFE}, -2},
+	}
+
+	for _, test := range tests {
+		device, peer := newFakeDevice(t)
+		reply(t, peer, test.raw...)
+
+		got, err := device.ReadInt24BE(0x20)
+		if err != nil {
+			t.Fatalf("ReadInt24BE of % x: %v", test.raw, err)
+		}
+		if got != test.want {
+			t.Errorf("ReadInt24BE of % x = %d, want %d", test.raw, got, test.want)
+		}
+		if reg := sent(t, peer); !bytes.Equal(reg, []byte{0x20}) {
+			t.Errorf("ReadInt24BE selected % x, want 20", reg)
+		}
+	}
+}
+
+func TestWriteInt24BE(t *testing.T) {
+	tests := []struct {
+		v    int32
+		want []byte
+	}{
+		{-1 << 23, []byte{0x30, 0x80, 0x00, 0x00}},
+		{1<<23 - 1, []byte{0x30, 0x7F, 0xFF, 0xFF}},
+		{-1, []byte{0x30, 0xFF, 0xFF, 0xFF}},
+		{0, []byte{0x30, 0x00, 0x00, 0x00}},
+	}
+
+	for _, test := range tests {
+		device, peer := newFakeDevice(t)
+		if err := device.WriteInt24BE(0x30, test.v); err != nil {
+			t.Fatalf("WriteInt24BE(%d): %v", test.v, err)
+		}
+		if got := sent(t, peer); !bytes.Equal(got, test.want) {
+			t.Errorf("WriteInt24BE(%d) sent % x, want % x", test.v, got, test.want)
+		}
+	}
+
+	device, _ := newFakeDevice(t)
+	for _, v := range []int32{1 << 23, -1<<23 - 1, math.MaxInt32, math.MinInt32} {
+		if err := device.WriteInt24BE(0x30, v); err != ErrOutOfRange {
+			t.Errorf("WriteInt24BE(%d) = %v, want ErrOutOfRange", v, err)
+		}
+	}
+	if stats := device.Stats(); stats.Writes != 0 {
+		t.Errorf("Out of range writes made %d transfers, want 0", stats.Writes)
+	}
+}
+
+func TestReadWords(t *testing.T) {
+	raw := []byte{0x12, 0x34, 0xAB, 0xCD, 0x00, 0xFF}
+	tests := []struct {
+		order binary.ByteOrder
+		want  []uint16
+	}{
+		{binary.BigEndian, []uint16{0x1234, 0xABCD, 0x00FF}},
+		{binary.LittleEndian, []uint16{0x3412, 0xCDAB, 0xFF00}},
+	}
+
+	for _, test := range tests {
+		device, peer := newFakeDevice(t)
+		reply(t, peer, raw...)
+
+		got, err := device.ReadWords(0x40, 3, test.order)
+		if err != nil {
+			t.Fatalf("ReadWords %v: %v", test.order, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ReadWords %v = %04x, want %04x", test.order, got, test.want)
+		}
+		if reg := sent(t, peer); !bytes.Equal(reg, []byte{0x40}) {
+			t.Errorf("ReadWords selected % x, want 40", reg)
+		}
+	}
+}
+
+func TestReadWordsShortRead(t *testing.T) {
+	// Cut off halfway through the second word.
+	device, peer := newFakeDevice(t)
+	reply(t, peer, 0x12, 0x34, 0xAB)
+
+	words, err := device.ReadWords(0x40, 2, binary.BigEndian)
+	if !errors.Is(err, ErrShortRead) {
+		t.Errorf("ReadWords of a short read = %04x, %v, want ErrShortRead", words, err)
+	}
+}
+
+func TestReadWordsNegativeCount(t *testing.T) {
+	device, _ := newFakeDevice(t)
+	if _, err := device.ReadWords(0x40, -1, binary.BigEndian); err == nil {
+		t.Error("ReadWords with a negative count succeeded")
+	}
+	if stats := device.Stats(); stats.Reads != 0 || stats.Writes != 0 {
+		t.Errorf("ReadWords with a negative count made transfers: %+v", stats)
+	}
+}
@@ -0,0 +1,64 @@
+package device
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FormatRegisterMap reads the registers from start to end inclusive, one
+// byte each, and lays them out like i2cdump: rows of 16 registers in hex
+// under a header of column indices, with the printable characters in a
+// gutter on the right. Registers that fail to read show as "--", so a map
+// can be taken of a device with holes in its register space. It only fails
+// when the device or its bus has been closed.
+func (device *I2C) FormatRegisterMap(start, end byte) (string, error) {
+	if end < start {
+		return "", fmt.Errorf("Invalid register range 0x%02x-0x%02x", start, end)
+	}
+
+	var b strings.Builder
+	b.WriteString("    ")
+	for col := 0; col < 16; col++ {
+		fmt.Fprintf(&b, " %x ", col)
+	}
+	b.WriteString("   0123456789abcdef\n")
+
+	buf := getBuffer(1)
+	defer putBuffer(buf)
+
+	for row := int(start) &^ 0x0F; row <= int(end); row += 16 {
+		var ascii [16]byte
+		fmt.Fprintf(&b, "%02x: ", row)
+		for col := 0; col < 16; col++ {
+			reg := row + col
+			if reg < int(start) || reg > int(end) {
+				b.WriteString("   ")
+				ascii[col] = ' '
+				continue
+			}
+
+			err := device.attempt(func() error {
+				return device.readRegisterFull(byte(reg), *buf, nil)
+			})
+			if Classify(err) == Fatal {
+				return "", err
+			} else if err != nil {
+				b.WriteString("-- ")
+				ascii[col] = '.'
+				continue
+			}
+
+			v := (*buf)[0]
+			fmt.Fprintf(&b, "%02x ", v)
+			if v >= 0x20 && v < 0x7F {
+				ascii[col] = v
+			} else {
+				ascii[col] = '.'
+			}
+		}
+
+		fmt.Fprintf(&b, "   %s\n", ascii[:])
+	}
+
+	return b.String(), nil
+}
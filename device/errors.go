@@ -0,0 +1,83 @@
+package device
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var (
+	ErrClosed         = errors.New("Device is closed")
+	ErrBusClosed      = errors.New("Bus is closed")
+	ErrInvalidAddress = errors.New("Invalid i2c address")
+	ErrBusNotFound    = errors.New("i2c bus not found")
+	ErrPermission     = errors.New("Permission denied opening i2c bus")
+	ErrTimeout        = errors.New("i2c operation timed out")
+	ErrShortRead      = errors.New("Short read from i2c device")
+	ErrReadOnly       = errors.New("Device was opened read-only")
+	ErrNilUUID        = errors.New("Refusing to write the nil UUID")
+
+	ErrUUIDLengthMismatch = errors.New("Device reports an unexpected UUID length")
+)
+
+// How bad an error is, for deciding whether an operation is worth retrying.
+type Severity int
+
+const (
+	// The operation may succeed if tried again.
+	Transient Severity = iota
+	// The operation will keep failing the same way, but the handle is fine.
+	Permanent
+	// The handle can't be used anymore.
+	Fatal
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Transient:
+		return "transient"
+	case Permanent:
+		return "permanent"
+	case Fatal:
+		return "fatal"
+	}
+
+	return "unknown"
+}
+
+// Classify sorts an error returned by this package into a Severity. It looks
+// through wrapped errors for the package's sentinels and the errno reported
+// by the kernel:
+//
+//   - Fatal: ErrClosed, ErrBusClosed, os.ErrClosed and EBADF.
+//   - Transient: ErrTimeout, ErrShortRead, and EAGAIN (arbitration lost),
+//     EBUSY, ETIMEDOUT, EINTR, EIO and EREMOTEIO (NAK).
+//   - Permanent: ErrInvalidAddress, ErrBusNotFound, ErrPermission,
+//     ErrReadOnly, ErrNilUUID, ENXIO (nothing answering at the address) and
+//     anything else not listed above.
+//
+// A nil error is Transient, there's nothing stopping the caller carrying on.
+func Classify(err error) Severity {
+	switch {
+	case err == nil:
+		return Transient
+	case errors.Is(err, ErrClosed), errors.Is(err, ErrBusClosed),
+		errors.Is(err, os.ErrClosed):
+		return Fatal
+	case errors.Is(err, ErrTimeout), errors.Is(err, ErrShortRead):
+		return Transient
+	}
+
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		switch errno {
+		case syscall.EBADF:
+			return Fatal
+		case syscall.EAGAIN, syscall.EBUSY, syscall.ETIMEDOUT, syscall.EINTR,
+			syscall.EIO, syscall.EREMOTEIO:
+			return Transient
+		}
+	}
+
+	return Permanent
+}
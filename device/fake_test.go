@@ -0,0 +1,85 @@
+package device
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Returns a device whose descriptor is one end of a SOCK_SEQPACKET socket
+// pair, and the other end. Every transfer the device writes arrives at the
+// peer as one packet, and every read the device makes takes the next packet
+// the test queued with reply, so short reads can be played back exactly.
+// Combined transfers need the I2C_RDWR ioctl and fail on the fake.
+func newFakeDevice(t testing.TB, opts ...Option) (*I2C, *os.File) {
+	t.Helper()
+
+	fds, err := syscall.Socketpair(syscall.AF_UNIX,
+		syscall.SOCK_SEQPACKET|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Fatalf("Creating socket pair: %v", err)
+	}
+	for _, fd := range fds {
+		if err := syscall.SetNonblock(fd, true); err != nil {
+			t.Fatalf("Setting socket non-blocking: %v", err)
+		}
+	}
+
+	rc := os.NewFile(uintptr(fds[0]), "fake-device")
+	peer := os.NewFile(uintptr(fds[1]), "fake-peer")
+	t.Cleanup(func() {
+		rc.Close()
+		peer.Close()
+	})
+
+	opts = append([]Option{WithReadDelay(0)}, opts...)
+	return newI2C(rc, 0x48, &sync.Mutex{}, opts), peer
+}
+
+// Queues data as the answer to the device's next read.
+func reply(t *testing.T, peer *os.File, data ...byte) {
+	t.Helper()
+	if _, err := peer.Write(data); err != nil {
+		t.Fatalf("Queueing reply: %v", err)
+	}
+}
+
+// Returns the next transfer the device wrote.
+func sent(t *testing.T, peer *os.File) []byte {
+	t.Helper()
+
+	buf := make([]byte, 256)
+	peer.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := peer.Read(buf)
+	if err != nil {
+		t.Fatalf("Reading what the device sent: %v", err)
+	}
+
+	return buf[:n]
+}
+
+// Answers every register the device selects with reply until the test ends,
+// for benchmarks that make a read per iteration. It doesn't allocate, so it
+// stays out of the allocation counts.
+func serveReply(tb testing.TB, peer *os.File, reply []byte) {
+	done := make(chan struct{})
+	tb.Cleanup(func() {
+		peer.SetReadDeadline(time.Now())
+		<-done
+	})
+
+	go func() {
+		defer close(done)
+		var buf [256]byte
+		for {
+			if _, err := peer.Read(buf[:]); err != nil {
+				return
+			}
+			if _, err := peer.Write(reply); err != nil {
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,187 @@
+package device
+
+import (
+	"github.com/gocql/gocql"
+	"sync"
+	"time"
+)
+
+// A failure for FaultInjector to inject into the calls it matches.
+type FaultRule struct {
+	// Registers the rule applies to, taken from the register argument or the
+	// first byte written. Empty matches every call, including plain Read.
+	Registers []byte
+	// Only every Nth matching call fails, 0 or 1 fail all of them.
+	Every int
+	// Returned instead of making the call, typically an errno such as
+	// syscall.EREMOTEIO. Nil makes the rule only add latency.
+	Err error
+	// Added before every matching call, whether it fails or not.
+	Latency time.Duration
+}
+
+func (rule FaultRule) matches(reg byte, hasReg bool) bool {
+	if len(rule.Registers) == 0 {
+		return true
+	}
+	if !hasReg {
+		return false
+	}
+
+	for _, r := range rule.Registers {
+		if r == reg {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FaultInjector wraps a Conn and fails or delays calls to it according to a
+// set of rules, for exercising retry and recovery paths. Rules count the calls
+// they match, so a given sequence of calls always fails the same way; Reset
+// starts the count over to replay it. When several rules fail a call the
+// first one's error is returned, and the latency of every matching rule adds
+// up. Close is always forwarded.
+type FaultInjector struct {
+	conn Conn
+
+	mu     sync.Mutex
+	rules  []FaultRule
+	counts []int
+}
+
+var _ Conn = (*FaultInjector)(nil)
+
+func NewFaultInjector(conn Conn, rules ...FaultRule) *FaultInjector {
+	f := &FaultInjector{conn: conn}
+	f.SetRules(rules...)
+	return f
+}
+
+// SetRules replaces the rules and resets their counts. It is safe to call
+// while other goroutines are using the injector.
+func (f *FaultInjector) SetRules(rules ...FaultRule) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append([]FaultRule(nil), rules...)
+	f.counts = make([]int, len(rules))
+}
+
+// AddRule adds a rule after the existing ones, with a fresh count.
+func (f *FaultInjector) AddRule(rule FaultRule) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append(f.rules, rule)
+	f.counts = append(f.counts, 0)
+}
+
+// Reset zeroes the count of every rule, so the same calls fail again in the
+// same places.
+func (f *FaultInjector) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.counts {
+		f.counts[i] = 0
+	}
+}
+
+// Applies the rules to a call, sleeping for their latency, and returns the
+// error the call should fail with, if any.
+func (f *FaultInjector) inject(reg byte, hasReg bool) error {
+	var latency time.Duration
+	var err error
+
+	f.mu.Lock()
+	for i, rule := range f.rules {
+		if !rule.matches(reg, hasReg) {
+			continue
+		}
+
+		f.counts[i]++
+		latency += rule.Latency
+		if err == nil && rule.Err != nil &&
+			(rule.Every <= 1 || f.counts[i]%rule.Every == 0) {
+			err = rule.Err
+		}
+	}
+	f.mu.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+
+	return err
+}
+
+func (f *FaultInjector) Addr() uint8 {
+	return f.conn.Addr()
+}
+
+func (f *FaultInjector) Read(p []byte) (int, error) {
+	if err := f.inject(0, false); err != nil {
+		return 0, err
+	}
+
+	return f.conn.Read(p)
+}
+
+func (f *FaultInjector) Write(buf []byte) (int, error) {
+	if err := f.inject(firstByte(buf)); err != nil {
+		return 0, err
+	}
+
+	return f.conn.Write(buf)
+}
+
+func (f *FaultInjector) Close() error {
+	return f.conn.Close()
+}
+
+func (f *FaultInjector) ReadRegister(reg byte) ([]byte, error) {
+	if err := f.inject(reg, true); err != nil {
+		return nil, err
+	}
+
+	return f.conn.ReadRegister(reg)
+}
+
+func (f *FaultInjector) WriteRegister(reg byte, data []byte) error {
+	if err := f.inject(reg, true); err != nil {
+		return err
+	}
+
+	return f.conn.WriteRegister(reg, data)
+}
+
+func (f *FaultInjector) SequentialRead(startReg byte, n int) ([]byte, error) {
+	if err := f.inject(startReg, true); err != nil {
+		return nil, err
+	}
+
+	return f.conn.SequentialRead(startReg, n)
+}
+
+func (f *FaultInjector) WriteRead(w []byte, r []byte) error {
+	if err := f.inject(firstByte(w)); err != nil {
+		return err
+	}
+
+	return f.conn.WriteRead(w, r)
+}
+
+func (f *FaultInjector) UUID() (gocql.UUID, error) {
+	if err := f.inject(UUIDRegister, true); err != nil {
+		return gocql.UUID{}, err
+	}
+
+	return f.conn.UUID()
+}
+
+func firstByte(buf []byte) (byte, bool) {
+	if len(buf) == 0 {
+		return 0, false
+	}
+
+	return buf[0], true
+}
@@ -9,10 +9,14 @@
 package device
 
 import (
+	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"github.com/gocql/gocql"
+	"golang.org/x/time/rate"
 	"os"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -22,74 +26,649 @@ const (
 	VersionRegister = 0x05
 	UUIDRegister    = 0x06
 	UUIDLength      = 16
+
+	// Default register that firmware with length-prefixed UUIDs reports the
+	// length in.
+	UUIDLengthRegister = 0x07
+
+	// Time given to a device to prepare a register after it was selected.
+	defaultReadDelay = 10 * time.Millisecond
+
+	// Most bytes i2c-dev reads in one go; it silently truncates longer reads.
+	i2cDevMaxRead = 8192
 )
 
 // I2C represents a connection to an i2c device.
 type I2C struct {
 	rc         *os.File
 	identifier gocql.UUID
+	exclusive  bool
+	readDelay  time.Duration
+
+	// Largest single transfer the adapter accepts, 0 for no limit.
+	maxTransfer  int
+	retry        *RetryPolicy
+	logNAKs      bool
+	recoverBus   func() error
+	readOnly     bool
+	timing       bool
+	allowNilUUID bool
+	byteOrder    binary.ByteOrder
+	uuidLenReg   byte
+	verify       bool
+	limiter      *rate.Limiter
+	autoIncMask  byte
+	initHooks    []func(*I2C) error
+
+	statsMu sync.Mutex
+	stats   Metrics
+	// Consecutive transfers that failed with EREMOTEIO, for WithAutoRecover.
+	remoteIOFailures int
+	autoRecover      int
+	// Whether the device acknowledged a transfer in the current transaction.
+	acked bool
+
+	// Serializes transactions. Devices obtained from a Bus share its mutex
+	// and select their address at the start of every transaction.
+	mu     *sync.Mutex
+	bus    *Bus
+	addr   uint8
+	closed bool
 }
 
-// New opens a connection to an i2c device.
-func New(addr uint8, bus int) (*I2C, error) {
-	f, err := os.OpenFile(fmt.Sprintf("/dev/i2c-%d", bus), os.O_RDWR, 0600)
+// New opens a connection to an i2c device. The descriptor is opened with
+// O_CLOEXEC so it isn't inherited by child processes across exec.
+func New(addr uint8, bus int, opts ...Option) (*I2C, error) {
+	if addr > 0x7F {
+		return nil, ErrInvalidAddress
+	}
+
+	device := newI2C(nil, addr, &sync.Mutex{}, opts)
+	flag := os.O_RDWR
+	if device.readOnly {
+		flag = os.O_RDONLY
+	}
+
+	f, err := openBusFile(bus, flag)
 	if err != nil {
 		return nil, err
 	}
-	if err := ioctl(f.Fd(), i2c_SLAVE, uintptr(addr)); err != nil {
+	if err := selectAddress(f, addr); err != nil {
+		f.Close()
 		return nil, err
 	}
 
+	device.rc = f
+	if err := device.verifyPresence(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := device.runInitHooks(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return device, nil
+}
+
+func openBusFile(bus int, flag int) (*os.File, error) {
+	f, err := os.OpenFile(fmt.Sprintf("/dev/i2c-%d", bus),
+		flag|syscall.O_CLOEXEC, 0600)
+	if err != nil {
+		switch {
+		case os.IsNotExist(err):
+			return nil, ErrBusNotFound
+		case os.IsPermission(err):
+			return nil, ErrPermission
+		}
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Points the descriptor at the slave with address addr.
+func selectAddress(f *os.File, addr uint8) error {
+	err := ioctl(f.Fd(), i2c_SLAVE, uintptr(addr))
+	if err == syscall.EINVAL {
+		return ErrInvalidAddress
+	}
+
+	return err
+}
+
+func newI2C(rc *os.File, addr uint8, mu *sync.Mutex, opts []Option) *I2C {
 	var placeholderUUID = [16]byte{}
-	return &I2C{f, placeholderUUID}, nil
+	device := &I2C{
+		rc:         rc,
+		identifier: placeholderUUID,
+		readDelay:  defaultReadDelay,
+		byteOrder:  binary.BigEndian,
+		uuidLenReg: UUIDLengthRegister,
+		mu:         mu,
+		addr:       addr,
+	}
+	for _, opt := range opts {
+		opt(device)
+	}
+
+	return device
+}
+
+// Addr returns the 7 bit address of the device on its bus.
+func (device *I2C) Addr() uint8 {
+	return device.addr
 }
 
 // Write sends buf to the remote i2c device. The interpretation of
 // the message is implementation dependant.
-func (i2c *I2C) Write(buf []byte) (int, error) {
-	return i2c.rc.Write(buf)
+func (i2c *I2C) Write(buf []byte) (written int, err error) {
+	err = i2c.transactWrite(func() error {
+		written, err = i2c.write(buf)
+		return err
+	})
+	return
 }
 
-func (i2c *I2C) WriteByte(b byte) (int, error) {
-	var buf [1]byte
-	buf[0] = b
-	return i2c.rc.Write(buf[:])
+func (i2c *I2C) WriteByte(b byte) (written int, err error) {
+	err = i2c.transact(func() error {
+		written, err = i2c.writeByte(b)
+		return err
+	})
+	return
 }
 
-func (i2c *I2C) Read(p []byte) (int, error) {
-	return i2c.rc.Read(p)
+// Writes data to the registers starting at reg. When data doesn't fit in a
+// single transfer it is split up, and each piece is prefixed with the register
+// it starts at, relying on the device auto-incrementing within a piece.
+func (device *I2C) WriteRegister(reg byte, data []byte) error {
+	return device.transactWrite(func() error {
+		return device.writeRegister(reg, data)
+	})
 }
 
-// Writes what register should be read from, waits 10 miliseconds and then
-// reads from the i2c device.
+// Writes buf one byte at a time, waiting delay between bytes, for devices that
+// NAK when bytes arrive faster than they can process them. Stops at the
+// first failed byte and returns how many were written before it.
+func (device *I2C) WriteBytesDelayed(buf []byte, delay time.Duration) (written int, err error) {
+	err = device.transactWrite(func() error {
+		written = 0
+		for i, b := range buf {
+			if i > 0 && delay > 0 {
+				time.Sleep(delay)
+			}
+			if _, err := device.writeByte(b); err != nil {
+				return err
+			}
+			written++
+		}
+
+		return nil
+	})
+	return
+}
+
+// Read reads len(p) bytes from the device. Reads longer than MaxReadLen are
+// split into several transfers, which relies on the device carrying on where
+// the previous one stopped. A transfer coming back short ends the read early.
+func (i2c *I2C) Read(p []byte) (read int, err error) {
+	err = i2c.transact(func() error {
+		read, err = i2c.read(p)
+		return err
+	})
+	return
+}
+
+// Writes what register should be read from, waits for the configured read
+// delay (10 miliseconds by default) and then reads from the i2c device.
 func (device *I2C) ReadRegister(readRegister byte) ([]byte, error) {
-	device.WriteByte(readRegister)
-	time.Sleep(time.Millisecond * 10)
-	readBuffer := make([]byte, 2, 2)
-	read, err := device.Read(readBuffer)
+	return device.ReadRegisterFunc(readRegister, nil)
+}
+
+// Like ReadRegister, but calls settle after the register was written and
+// before reading, for devices that signal data-ready some other way. A nil
+// settle waits for the configured read delay.
+func (device *I2C) ReadRegisterFunc(readRegister byte, settle func()) (readBuffer []byte, err error) {
+	err = device.transact(func() error {
+		readBuffer, err = device.readRegister(readRegister, settle)
+		return err
+	})
+	return
+}
+
+// Like ReadRegister, but the whole read including waiting for the bus and the
+// settle delay, and waiting for the rate limit with WithRateLimit, has to be
+// done by deadline. ErrTimeout is returned without
+// reading if the settle delay wouldn't fit before the deadline. The read
+// itself is a blocking syscall that is only bounded by the adapter's own
+// timeout, so if it finishes late its result is thrown away and ErrTimeout
+// returned. The read isn't retried.
+func (device *I2C) ReadRegisterDeadline(reg byte, deadline time.Time) (readBuffer []byte, err error) {
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	err = device.attemptContext(ctx, func() error {
+		if !time.Now().Add(device.readDelay).Before(deadline) {
+			return ErrTimeout
+		}
+
+		readBuffer, err = device.readRegister(reg, nil)
+		if err == nil && time.Now().After(deadline) {
+			return ErrTimeout
+		}
+
+		return err
+	})
 	if err != nil {
-		return readBuffer, err
-	} else if read != 2 {
-		return readBuffer, errors.New("Didn't read 2 bytes")
+		return nil, err
 	}
 
 	return readBuffer, nil
 }
 
+// Like ReadRegister, but returns however many bytes the device sent, up to
+// maxLen, instead of insisting on exactly two. Only a failed read is an error,
+// which makes it suitable for probing devices with unknown response lengths.
+func (device *I2C) TryReadRegister(reg byte, maxLen int) (buf []byte, err error) {
+	if maxLen < 0 {
+		return nil, errors.New("Negative read length")
+	}
+
+	buf = make([]byte, maxLen)
+	var read int
+	err = device.transact(func() error {
+		read, err = device.readRegisterInto(reg, buf, nil)
+		return err
+	})
+	return buf[:read], err
+}
+
+// Reads n consecutive bytes starting at startReg in one read, relying on the
+// device auto-incrementing its register pointer.
+func (device *I2C) SequentialRead(startReg byte, n int) (buf []byte, err error) {
+	if n < 0 {
+		return nil, errors.New("Negative read length")
+	}
+
+	err = device.transact(func() error {
+		buf, err = device.sequentialRead(startReg, n)
+		return err
+	})
+	return
+}
+
 // Gets the stored UUID from the I2C device. This identifier matches up with
 // the uuid stored in the database.
-func (device *I2C) UUID() (gocql.UUID, error) {
+func (device *I2C) UUID() (uuid gocql.UUID, err error) {
+	err = device.transact(func() error {
+		uuid, err = device.readUUID()
+		return err
+	})
+	return
+}
+
+// Writes uuid to the device. The nil UUID marks an unprovisioned device, so
+// writing it is refused with ErrNilUUID unless WithAllowNilUUID was given.
+func (device *I2C) WriteUUID(uuid gocql.UUID) error {
+	if err := device.checkUUID(uuid); err != nil {
+		return err
+	}
+
+	return device.transactWrite(func() error {
+		return device.writeUUID(uuid)
+	})
+}
+
+// Flush syncs the underlying file. The i2c-dev driver doesn't buffer, every
+// write is a complete transfer on the bus by the time it returns, so there is
+// never anything to flush. The driver doesn't implement fsync at all and the
+// EINVAL that gets is ignored, so Flush only fails on real errors such as a
+// closed device.
+func (device *I2C) Flush() error {
+	return device.attempt(func() error {
+		err := device.rc.Sync()
+		if errors.Is(err, syscall.EINVAL) {
+			return nil
+		}
+
+		return err
+	})
+}
+
+// Closes the connection. Devices obtained from a Bus don't own the bus file
+// descriptor, so closing one only stops it being used and the Bus has to be
+// closed as well.
+func (i2c *I2C) Close() error {
+	i2c.mu.Lock()
+	defer i2c.mu.Unlock()
+	if i2c.closed {
+		return ErrClosed
+	}
+
+	i2c.closed = true
+	if i2c.bus != nil {
+		return nil
+	}
+	if i2c.exclusive {
+		syscall.Flock(int(i2c.rc.Fd()), syscall.LOCK_UN)
+	}
+
+	return i2c.rc.Close()
+}
+
+// Runs op as a single transaction on the bus. Other handles that respect the
+// same locking can't interleave their own reads and writes with op. Failed
+// transactions are run again as the retry policy allows, so op has to be safe
+// to repeat.
+func (device *I2C) transact(op func() error) error {
+	return device.transactContext(context.Background(), op)
+}
+
+// Like transact, but waiting for the rate limiter stops when ctx is done.
+func (device *I2C) transactContext(ctx context.Context, op func() error) error {
+	return device.retryLoop(ctx, op, false)
+}
+
+// Like transact, for ops that write in several transfers. Once one of them
+// has gone through, running op again would send the device the same bytes a
+// second time, so op is only retried while nothing has been acknowledged.
+func (device *I2C) transactWrite(op func() error) error {
+	return device.retryLoop(context.Background(), op, true)
+}
+
+func (device *I2C) retryLoop(ctx context.Context, op func() error, once bool) error {
+	for attempt := 0; ; attempt++ {
+		device.autoRecoverBus()
+		acked, err := device.attemptAcked(ctx, op)
+		if (once && acked) || !device.retry.retry(attempt, err) {
+			return err
+		}
+
+		time.Sleep(device.retry.Delay(attempt))
+	}
+}
+
+// Runs op once, without retrying.
+func (device *I2C) attempt(op func() error) error {
+	return device.attemptContext(context.Background(), op)
+}
+
+func (device *I2C) attemptContext(ctx context.Context, op func() error) error {
+	_, err := device.attemptAcked(ctx, op)
+	return err
+}
+
+// Like attemptContext, also reporting whether any transfer of op was
+// acknowledged.
+func (device *I2C) attemptAcked(ctx context.Context, op func() error) (bool, error) {
+	if device.limiter != nil {
+		if err := device.limiter.Wait(ctx); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return false, err
+			}
+			return false, ErrTimeout
+		}
+	}
+
+	if err := device.acquire(); err != nil {
+		return false, err
+	}
+	defer device.release()
+
+	err := op()
+	device.statsMu.Lock()
+	acked := device.acked
+	device.statsMu.Unlock()
+	return acked, err
+}
+
+// Takes the device mutex, points a shared bus descriptor at this device and
+// takes the advisory lock on the bus when exclusive access was requested.
+func (device *I2C) acquire() error {
+	device.mu.Lock()
+	if device.closed {
+		device.mu.Unlock()
+		return ErrClosed
+	}
+	if device.bus != nil {
+		if device.bus.closed {
+			device.mu.Unlock()
+			return ErrBusClosed
+		}
+
+		err := selectAddress(device.rc, device.addr)
+		if err != nil {
+			device.mu.Unlock()
+			return err
+		}
+	}
+
+	if device.exclusive {
+		err := syscall.Flock(int(device.rc.Fd()), syscall.LOCK_EX)
+		if err != nil {
+			device.mu.Unlock()
+			return err
+		}
+	}
+
+	device.acked = false
+	return nil
+}
+
+func (device *I2C) release() {
+	if device.exclusive {
+		syscall.Flock(int(device.rc.Fd()), syscall.LOCK_UN)
+	}
+	device.mu.Unlock()
+}
+
+// The methods below talk to the bus directly and must only be called from
+// within a transaction.
+
+// Writes buf, split into transfers of at most maxTransfer bytes.
+func (i2c *I2C) write(buf []byte) (int, error) {
+	if i2c.readOnly {
+		return 0, ErrReadOnly
+	}
+	if i2c.maxTransfer <= 0 || len(buf) <= i2c.maxTransfer {
+		return i2c.transfer(true, buf)
+	}
+
+	written := 0
+	for written < len(buf) {
+		end := written + i2c.maxTransfer
+		if end > len(buf) {
+			end = len(buf)
+		}
+
+		n, err := i2c.transfer(true, buf[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+func (device *I2C) writeRegister(reg byte, data []byte) error {
+	if device.maxTransfer == 1 {
+		return errors.New("Register writes don't fit in a max transfer of 1 byte")
+	}
+
+	chunk := len(data)
+	if device.maxTransfer > 1 && chunk > device.maxTransfer-1 {
+		chunk = device.maxTransfer - 1
+	}
+
+	buf := make([]byte, 0, chunk+1)
+	offset := 0
+	for {
+		end := offset + chunk
+		if end > len(data) {
+			end = len(data)
+		}
+
+		buf = append(buf[:0], reg+byte(offset))
+		buf = append(buf, data[offset:end]...)
+		if _, err := device.write(buf); err != nil {
+			return err
+		}
+
+		offset = end
+		if offset >= len(data) {
+			return nil
+		}
+	}
+}
+
+func (i2c *I2C) writeByte(b byte) (int, error) {
+	var buf [1]byte
+	buf[0] = b
+	return i2c.write(buf[:])
+}
+
+// Reads into p, split into transfers of at most maxReadLen bytes.
+func (i2c *I2C) read(p []byte) (int, error) {
+	limit := i2c.maxReadLen()
+	if len(p) <= limit {
+		return i2c.transfer(false, p)
+	}
+
+	read := 0
+	for read < len(p) {
+		end := read + limit
+		if end > len(p) {
+			end = len(p)
+		}
+
+		n, err := i2c.transfer(false, p[read:end])
+		if err != nil || n < end-read {
+			return read + n, err
+		}
+		read = end
+	}
+
+	return read, nil
+}
+
+func (device *I2C) maxReadLen() int {
+	if device.maxTransfer > 0 && device.maxTransfer < i2cDevMaxRead {
+		return device.maxTransfer
+	}
+
+	return i2cDevMaxRead
+}
+
+// MaxReadLen returns the most bytes a read passes to the kernel at once: the
+// 8192 byte limit of i2c-dev, or the WithMaxTransfer limit when it's lower.
+// The adapter's own limit isn't reported to user space, so an adapter with a
+// smaller buffer needs WithMaxTransfer.
+func (device *I2C) MaxReadLen() (int, error) {
+	device.mu.Lock()
+	defer device.mu.Unlock()
+	if device.closed {
+		return 0, ErrClosed
+	} else if device.bus != nil && device.bus.closed {
+		return 0, ErrBusClosed
+	}
+
+	return device.maxReadLen(), nil
+}
+
+// Selects reg, waits for it to settle and reads up to len(p) bytes of it.
+func (device *I2C) readRegisterInto(reg byte, p []byte, settle func()) (int, error) {
+	if err := device.selectRegister(reg); err != nil {
+		return 0, err
+	}
+	if settle != nil {
+		settle()
+	} else {
+		time.Sleep(device.readDelay)
+	}
+
+	return device.read(p)
+}
+
+// Points the device's register pointer at reg. A read-only descriptor can't be
+// written, but the I2C_RDWR ioctl still works on it.
+func (device *I2C) selectRegister(reg byte) error {
+	if device.readOnly {
+		return device.writeRead([]byte{reg}, nil)
+	}
+
+	_, err := device.writeByte(reg)
+	return err
+}
+
+// Like readRegisterInto, but fails unless all of p was read.
+func (device *I2C) readRegisterFull(reg byte, p []byte, settle func()) error {
+	read, err := device.readRegisterInto(reg, p, settle)
+	if err != nil {
+		return err
+	} else if read != len(p) {
+		return ErrShortRead
+	}
+
+	return nil
+}
+
+// The returned slice is the caller's, so it is read into directly instead of
+// going through a pooled buffer.
+func (device *I2C) readRegister(readRegister byte, settle func()) ([]byte, error) {
+	readBuffer := make([]byte, 2, 2)
+	err := device.readRegisterFull(readRegister, readBuffer, settle)
+	return readBuffer, err
+}
+
+// Reads a two byte register as a word in the given byte order.
+func (device *I2C) readUint16(reg byte, order binary.ByteOrder) (value uint16, err error) {
+	buf := getBuffer(2)
+	defer putBuffer(buf)
+
+	err = device.transact(func() error {
+		return device.readRegisterFull(reg, *buf, nil)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return order.Uint16(*buf), nil
+}
+
+func (device *I2C) sequentialRead(startReg byte, n int) ([]byte, error) {
+	if n < 0 {
+		return nil, errors.New("Negative read length")
+	}
+
+	buf := make([]byte, n)
+	read, err := device.sequentialReadInto(startReg, buf)
+	return buf[:read], err
+}
+
+// Like sequentialRead, but reads into p and fails unless all of it was read.
+func (device *I2C) sequentialReadInto(startReg byte, p []byte) (int, error) {
+	read, err := device.readRegisterInto(startReg|device.autoIncMask, p, nil)
+	if err == nil && read != len(p) {
+		err = ErrShortRead
+	}
+
+	return read, err
+}
+
+func (device *I2C) readUUID() (gocql.UUID, error) {
 	uuid := [16]byte{}
+	buf := getBuffer(2)
+	defer putBuffer(buf)
+
 	var i byte
 	for i = 0; i < UUIDLength; {
-		var err error
-		var buf []byte
-		buf, err = device.ReadRegister(UUIDRegister)
+		err := device.readRegisterFull(UUIDRegister, *buf, nil)
 		if err != nil {
 			return uuid, err
 		}
 
-		for _, currentByte := range buf {
+		for _, currentByte := range *buf {
 			uuid[i] = currentByte
 			i++
 		}
@@ -99,11 +678,21 @@ func (device *I2C) UUID() (gocql.UUID, error) {
 	return uuid, nil
 }
 
-func (device *I2C) WriteUUID(uuid gocql.UUID) error {
+func (device *I2C) checkUUID(uuid gocql.UUID) error {
+	if uuid == (gocql.UUID{}) && !device.allowNilUUID {
+		return ErrNilUUID
+	}
+
+	return nil
+}
+
+func (device *I2C) writeUUID(uuid gocql.UUID) error {
 	var i byte
 	for i = 0; i < UUIDLength; i++ {
-		written, err := device.WriteByte(uuid[i])
-		if err != nil || written != 1 {
+		written, err := device.writeByte(uuid[i])
+		if err != nil {
+			return err
+		} else if written != 1 {
 			return errors.New("Couldn't write UUID")
 		}
 	}
@@ -111,10 +700,6 @@ func (device *I2C) WriteUUID(uuid gocql.UUID) error {
 	return nil
 }
 
-func (i2c *I2C) Close() error {
-	return i2c.rc.Close()
-}
-
 func ioctl(fd, cmd, arg uintptr) (err error) {
 	_, _, e1 := syscall.Syscall6(syscall.SYS_IOCTL, fd, cmd, arg, 0, 0, 0)
 	if e1 != 0 {
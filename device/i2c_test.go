@@ -0,0 +1,15 @@
+package device
+
+import (
+	"testing"
+)
+
+func TestSequentialReadNegativeLength(t *testing.T) {
+	device, _ := newFakeDevice(t)
+	if _, err := device.SequentialRead(0x40, -1); err == nil {
+		t.Error("SequentialRead with a negative length succeeded")
+	}
+	if _, err := device.SequentialReadUnlocked(0x40, -1); err == nil {
+		t.Error("SequentialReadUnlocked with a negative length succeeded")
+	}
+}
@@ -0,0 +1,131 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gocql/gocql"
+	"strings"
+)
+
+// What AssertIdentity expects of a device. Checks whose field is left nil or
+// false are skipped; the device always has to acknowledge its address.
+type IdentityCriteria struct {
+	// Registers holding the manufacturer and device IDs, as for ReadDeviceID.
+	MfgReg byte
+	DevReg byte
+	MfgID  *uint16
+	DevID  *uint16
+
+	// Oldest firmware version accepted.
+	MinVersion *Version
+	// Whether the device has to report a UUID other than the nil UUID.
+	RequireUUID bool
+}
+
+// Returned by AssertIdentity with every criterion the device failed.
+type IdentityError struct {
+	Addr     uint8
+	Failures []error
+}
+
+func (e *IdentityError) Error() string {
+	failures := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		failures[i] = failure.Error()
+	}
+
+	return fmt.Sprintf("Device at 0x%02x failed identity check: %s", e.Addr,
+		strings.Join(failures, "; "))
+}
+
+func (e *IdentityError) Unwrap() []error {
+	return e.Failures
+}
+
+// Checks that the device is the one expected, for guarding startup. Every
+// criterion is checked, and the ones that fail are returned together in an
+// *IdentityError. The only exceptions are a device that doesn't acknowledge
+// its address, which fails with ErrNoDevice alone since nothing else can be
+// read from it, and a device or bus that has been closed, which fails with
+// that error.
+func (device *I2C) AssertIdentity(expected IdentityCriteria) error {
+	identityErr := &IdentityError{Addr: device.addr}
+	fail := func(err error) error {
+		if Classify(err) == Fatal {
+			return err
+		}
+
+		identityErr.Failures = append(identityErr.Failures, err)
+		return nil
+	}
+
+	if err := device.probe(); err != nil {
+		if Classify(err) == Fatal {
+			return err
+		}
+
+		identityErr.Failures = []error{fmt.Errorf("%w: %w", ErrNoDevice, err)}
+		return identityErr
+	}
+
+	if expected.MfgID != nil || expected.DevID != nil {
+		if err := device.checkDeviceID(expected, fail); err != nil {
+			return err
+		}
+	}
+
+	if expected.MinVersion != nil {
+		var err error
+		version, readErr := device.ReadVersion()
+		if readErr != nil {
+			err = fail(fmt.Errorf("Reading version: %w", readErr))
+		} else if version.Compare(*expected.MinVersion) < 0 {
+			err = fail(fmt.Errorf("Firmware version %d.%d is older than %d.%d",
+				version.Major, version.Minor,
+				expected.MinVersion.Major, expected.MinVersion.Minor))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if expected.RequireUUID {
+		var err error
+		uuid, readErr := device.UUID()
+		if readErr != nil {
+			err = fail(fmt.Errorf("Reading UUID: %w", readErr))
+		} else if uuid == (gocql.UUID{}) {
+			err = fail(errors.New("Device reports the nil UUID"))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(identityErr.Failures) > 0 {
+		return identityErr
+	}
+
+	return nil
+}
+
+func (device *I2C) checkDeviceID(expected IdentityCriteria, fail func(error) error) error {
+	mfg, dev, err := device.ReadDeviceID(expected.MfgReg, expected.DevReg)
+	if err != nil {
+		return fail(fmt.Errorf("Reading device ID: %w", err))
+	}
+
+	if expected.MfgID != nil && mfg != *expected.MfgID {
+		err := fail(fmt.Errorf("Manufacturer ID is 0x%04x, expected 0x%04x",
+			mfg, *expected.MfgID))
+		if err != nil {
+			return err
+		}
+	}
+	if expected.DevID != nil && dev != *expected.DevID {
+		return fail(fmt.Errorf("Device ID is 0x%04x, expected 0x%04x", dev,
+			*expected.DevID))
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package device
+
+// Lock takes the device for a sequence of operations that mustn't be
+// interleaved with anyone else's, the same way every single operation takes
+// it for itself: the device mutex, which devices from one Bus share, and the
+// flock with WithExclusiveBus. It fails if the device or its bus was closed.
+//
+// Between Lock and Unlock only the Unlocked methods may be used. Every other
+// method takes the lock itself and deadlocks when called while it is held.
+// The Unlocked methods are also not retried.
+func (device *I2C) Lock() error {
+	return device.acquire()
+}
+
+// Unlock releases the device after Lock.
+func (device *I2C) Unlock() {
+	device.release()
+}
+
+// Like Read, for use between Lock and Unlock.
+func (device *I2C) ReadUnlocked(p []byte) (int, error) {
+	return device.read(p)
+}
+
+// Like Write, for use between Lock and Unlock.
+func (device *I2C) WriteUnlocked(buf []byte) (int, error) {
+	return device.write(buf)
+}
+
+// Like ReadRegister, for use between Lock and Unlock.
+func (device *I2C) ReadRegisterUnlocked(reg byte) ([]byte, error) {
+	return device.readRegister(reg, nil)
+}
+
+// Like WriteRegister, for use between Lock and Unlock.
+func (device *I2C) WriteRegisterUnlocked(reg byte, data []byte) error {
+	return device.writeRegister(reg, data)
+}
+
+// Like SequentialRead, for use between Lock and Unlock.
+func (device *I2C) SequentialReadUnlocked(startReg byte, n int) ([]byte, error) {
+	return device.sequentialRead(startReg, n)
+}
+
+// Like WriteRead, for use between Lock and Unlock.
+func (device *I2C) WriteReadUnlocked(w []byte, r []byte) error {
+	if err := device.checkWriteRead(w, r); err != nil {
+		return err
+	}
+
+	return device.writeRead(w, r)
+}
@@ -0,0 +1,150 @@
+package device
+
+import (
+	"errors"
+	"log"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrAddressNAK = errors.New("Device didn't acknowledge its address")
+	ErrDataNAK    = errors.New("Device didn't acknowledge data")
+)
+
+// Counters for the transfers a device has made, returned by Stats.
+//
+// The kernel only reports NAKs through the errno of a failed transfer.
+// ENXIO is documented as an address NAK. EREMOTEIO is returned by many
+// adapters for a NAK in either phase, so it is counted as a data NAK when the
+// device already acknowledged an earlier transfer in the same transaction and
+// as an address NAK otherwise. A NAK inside a single transfer can't be placed
+// any more precisely than that.
+//
+// The durations are only collected with WithTiming. They cover the syscall
+// of each transfer, not waiting for the bus or settle delays.
+type Metrics struct {
+	Reads       uint64
+	Writes      uint64
+	Errors      uint64
+	AddressNAKs uint64
+	DataNAKs    uint64
+
+	Timed         uint64
+	MinDuration   time.Duration
+	MaxDuration   time.Duration
+	TotalDuration time.Duration
+}
+
+// AvgDuration returns the average duration of the timed transfers.
+func (m Metrics) AvgDuration() time.Duration {
+	if m.Timed == 0 {
+		return 0
+	}
+
+	return m.TotalDuration / time.Duration(m.Timed)
+}
+
+// Stats returns a snapshot of the device's transfer counters.
+func (device *I2C) Stats() Metrics {
+	device.statsMu.Lock()
+	defer device.statsMu.Unlock()
+	return device.stats
+}
+
+// An errno from a transfer the device didn't acknowledge. It matches both
+// the NAK sentinel and the original errno with errors.Is.
+type nakError struct {
+	nak   error
+	errno syscall.Errno
+}
+
+func (e *nakError) Error() string {
+	return e.nak.Error() + ": " + e.errno.Error()
+}
+
+func (e *nakError) Unwrap() []error {
+	return []error{e.nak, e.errno}
+}
+
+// Makes a single read or write on the bus.
+func (device *I2C) transfer(write bool, buf []byte) (int, error) {
+	var start time.Time
+	if device.timing {
+		start = time.Now()
+	}
+
+	var n int
+	var err error
+	if write {
+		n, err = device.rc.Write(buf)
+	} else {
+		n, err = device.rc.Read(buf)
+	}
+
+	return n, device.record(write, start, err)
+}
+
+// Counts a finished transfer that was started at start, or at the zero time
+// if it wasn't timed, and returns its error, marked as a NAK where the errno
+// called for one.
+func (device *I2C) record(write bool, start time.Time, err error) error {
+	device.statsMu.Lock()
+	defer device.statsMu.Unlock()
+
+	if write {
+		device.stats.Writes++
+	} else {
+		device.stats.Reads++
+	}
+	if !start.IsZero() {
+		device.recordDuration(time.Since(start))
+	}
+	if err == nil {
+		device.acked = true
+		device.remoteIOFailures = 0
+		return nil
+	}
+	device.stats.Errors++
+
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		device.remoteIOFailures = 0
+		return err
+	}
+	if errno == syscall.EREMOTEIO {
+		device.remoteIOFailures++
+	} else {
+		device.remoteIOFailures = 0
+	}
+
+	var nak error
+	switch {
+	case errno == syscall.ENXIO, errno == syscall.EREMOTEIO && !device.acked:
+		device.stats.AddressNAKs++
+		nak = ErrAddressNAK
+	case errno == syscall.EREMOTEIO:
+		device.stats.DataNAKs++
+		nak = ErrDataNAK
+	default:
+		return err
+	}
+
+	if device.logNAKs {
+		log.Printf("i2c device 0x%02x: %v", device.addr, nak)
+	}
+
+	return &nakError{nak, errno}
+}
+
+func (device *I2C) recordDuration(elapsed time.Duration) {
+	stats := &device.stats
+	if stats.Timed == 0 || elapsed < stats.MinDuration {
+		stats.MinDuration = elapsed
+	}
+	if elapsed > stats.MaxDuration {
+		stats.MaxDuration = elapsed
+	}
+	stats.TotalDuration += elapsed
+	stats.Timed++
+}
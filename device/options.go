@@ -0,0 +1,229 @@
+package device
+
+import (
+	"encoding/binary"
+	"fmt"
+	"golang.org/x/time/rate"
+	"time"
+)
+
+// Option configures an I2C device when it is opened with New.
+type Option func(*I2C)
+
+// WithExclusiveBus takes an advisory flock(2) LOCK_EX on the /dev/i2c-N node
+// for the duration of every transaction, so processes sharing the bus take
+// turns instead of interleaving register writes and reads. The lock is
+// released on Close.
+//
+// The lock is advisory and only helps against other programs that flock the
+// same node. Shell tools can be made to cooperate with flock(1):
+//
+//	flock /dev/i2c-1 i2cget -y 1 0x48
+func WithExclusiveBus() Option {
+	return func(device *I2C) {
+		device.exclusive = true
+	}
+}
+
+// WithReadDelay sets how long register reads wait between selecting the
+// register and reading it back.
+func WithReadDelay(delay time.Duration) Option {
+	return func(device *I2C) {
+		device.readDelay = delay
+	}
+}
+
+// WithMaxTransfer limits single transfers to n bytes for adapters that can't
+// move more at once. Longer writes and reads are split into several transfers
+// in order. The default of 0 means no limit beyond the 8192 bytes i2c-dev
+// reads at most. Register writes need room for the register and at least one
+// byte of data, so they fail when n is 1.
+func WithMaxTransfer(n int) Option {
+	return func(device *I2C) {
+		device.maxTransfer = n
+	}
+}
+
+// WithRetryPolicy retries transactions that fail with a Transient error as the
+// policy allows. Writes that take several transfers, such as a split
+// WriteRegister, WriteBytesDelayed or WriteUUID, are only retried if none of
+// their transfers went through, so the device never sees bytes twice.
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return func(device *I2C) {
+		device.retry = policy
+	}
+}
+
+// WithNAKLogging logs every address and data NAK as it is counted, to help
+// track down flaky wiring.
+func WithNAKLogging() Option {
+	return func(device *I2C) {
+		device.logNAKs = true
+	}
+}
+
+// WithBusRecovery sets the routine RecoverBus runs, such as the Recover
+// method of RecoveryPins.
+func WithBusRecovery(recover func() error) Option {
+	return func(device *I2C) {
+		device.recoverBus = recover
+	}
+}
+
+// WithReadOnly opens the bus O_RDONLY, which also works where the process
+// only has read permission on /dev/i2c-N. Every write returns ErrReadOnly
+// without touching the bus. Selecting the slave address and the register to
+// read still work, through ioctls that don't need a writable descriptor.
+//
+// On a device obtained from a Bus the shared descriptor stays writable, but
+// the device refuses writes all the same. WriteRead and Chain only send a
+// register address of up to two bytes, and only ahead of a read.
+func WithReadOnly() Option {
+	return func(device *I2C) {
+		device.readOnly = true
+	}
+}
+
+// WithTiming records how long each transfer takes in the device's Metrics.
+// It is off by default to keep the clock out of every transfer.
+func WithTiming() Option {
+	return func(device *I2C) {
+		device.timing = true
+	}
+}
+
+// WithAllowNilUUID lets WriteUUID write the nil UUID, for provisioning flows
+// that deliberately return a device to the unprovisioned state.
+func WithAllowNilUUID() Option {
+	return func(device *I2C) {
+		device.allowNilUUID = true
+	}
+}
+
+// WithByteOrder sets the byte order ReadUint16, ReadInt16 and WriteUint16 use
+// for multi-byte registers. The default is big endian.
+func WithByteOrder(order binary.ByteOrder) Option {
+	return func(device *I2C) {
+		device.byteOrder = order
+	}
+}
+
+// WithUUIDLengthRegister sets the register ReadUUIDChecked reads the UUID
+// length from.
+func WithUUIDLengthRegister(reg byte) Option {
+	return func(device *I2C) {
+		device.uuidLenReg = reg
+	}
+}
+
+// WithAutoRecover runs RecoverBus before the next transaction once n
+// transfers in a row have failed with EREMOTEIO, which is how a wedged bus
+// usually shows up. Recovery is logged. Without a recovery routine configured
+// it only logs that recovery isn't supported.
+func WithAutoRecover(n int) Option {
+	return func(device *I2C) {
+		device.autoRecover = n
+	}
+}
+
+// The options a device was opened with, as returned by Config.
+type Config struct {
+	ReadDelay time.Duration
+	// Retries after the first attempt allowed by the retry policy.
+	Retries            int
+	ByteOrder          binary.ByteOrder
+	ReadOnly           bool
+	MaxTransfer        int
+	ExclusiveBus       bool
+	Timing             bool
+	NAKLogging         bool
+	AllowNilUUID       bool
+	AutoRecover        int
+	UUIDLengthRegister byte
+	VerifyPresence     bool
+	AutoIncrementMask  byte
+}
+
+// Config returns a snapshot of the device's configuration.
+func (device *I2C) Config() Config {
+	retries := 0
+	if device.retry != nil && device.retry.MaxAttempts > 1 {
+		retries = device.retry.MaxAttempts - 1
+	}
+
+	return Config{
+		ReadDelay:          device.readDelay,
+		Retries:            retries,
+		ByteOrder:          device.byteOrder,
+		ReadOnly:           device.readOnly,
+		MaxTransfer:        device.maxTransfer,
+		ExclusiveBus:       device.exclusive,
+		Timing:             device.timing,
+		NAKLogging:         device.logNAKs,
+		AllowNilUUID:       device.allowNilUUID,
+		AutoRecover:        device.autoRecover,
+		UUIDLengthRegister: device.uuidLenReg,
+		VerifyPresence:     device.verify,
+		AutoIncrementMask:  device.autoIncMask,
+	}
+}
+
+// WithVerifyPresence probes the device right after opening it and fails with
+// ErrNoDevice if nothing acknowledges the address, so a wrong address shows
+// up immediately. It is opt-in because some write-only devices don't answer
+// the probe.
+func WithVerifyPresence() Option {
+	return func(device *I2C) {
+		device.verify = true
+	}
+}
+
+// WithRateLimit caps the device at rps transactions per second. Each
+// transaction blocks until the limiter allows it; only Stream and
+// ReadRegisterDeadline stop waiting early, when their context is cancelled
+// or the deadline can't be met. Operations between Lock and Unlock aren't
+// limited.
+func WithRateLimit(rps float64) Option {
+	return WithRateLimiter(rate.NewLimiter(rate.Limit(rps), 1))
+}
+
+// WithRateLimiter is like WithRateLimit with a limiter of the caller's
+// choosing. Giving every device on a bus the same limiter caps the bus as a
+// whole rather than each device on its own.
+func WithRateLimiter(limiter *rate.Limiter) Option {
+	return func(device *I2C) {
+		device.limiter = limiter
+	}
+}
+
+// WithAutoIncrementMask ORs mask into the start register of every multi-byte
+// sequential read, for devices that only auto-increment their register
+// pointer when asked to. Many ST sensors want 0x80. This applies to
+// SequentialRead and everything built on it, such as ReadAt, ReadWords and
+// the 24 bit helpers, but not to ReadRegister.
+func WithAutoIncrementMask(mask byte) Option {
+	return func(device *I2C) {
+		device.autoIncMask = mask
+	}
+}
+
+// WithInitHook runs hook on the device once it has been opened, for bring-up
+// such as a reset sequence or setting a config register. Hooks run in the
+// order they were given, after WithVerifyPresence's probe, and also run for
+// devices obtained with Bus.Device. If a hook fails New closes the device and
+// fails with the hook's error.
+func WithInitHook(hook func(*I2C) error) Option {
+	return func(device *I2C) {
+		device.initHooks = append(device.initHooks, hook)
+	}
+}
+
+func (device *I2C) runInitHooks() error {
+	for i, hook := range device.initHooks {
+		if err := hook(device); err != nil {
+			return fmt.Errorf("Init hook %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+// Package periphconn exposes devices from the device package as periph.io
+// connections, so periph device drivers can run on top of them. It lives in
+// its own package so that users who don't need periph don't depend on it.
+package periphconn
+
+import (
+	"fmt"
+	"github.com/MooreGuy/waterapp/device"
+	"periph.io/x/conn/v3"
+)
+
+// Conn adapts an *device.I2C to periph's conn.Conn.
+type Conn struct {
+	dev *device.I2C
+}
+
+var _ conn.Conn = &Conn{}
+
+func New(dev *device.I2C) *Conn {
+	return &Conn{dev}
+}
+
+func (c *Conn) String() string {
+	return fmt.Sprintf("i2c(%#x)", c.dev.Addr())
+}
+
+// Tx writes w and reads into r in one combined transfer.
+func (c *Conn) Tx(w, r []byte) error {
+	return c.dev.WriteRead(w, r)
+}
+
+func (c *Conn) Duplex() conn.Duplex {
+	return conn.Half
+}
@@ -0,0 +1,36 @@
+package device
+
+import (
+	"sync"
+)
+
+// Buffers larger than this aren't kept around for reuse.
+const maxPooledBuffer = 256
+
+// Scratch buffers for transfers, shared by all devices so that reads that
+// only return a decoded value, like ReadUint16, don't allocate. Pooled
+// buffers never leave the package; reads that return a slice to the caller
+// allocate it and read into it directly.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 32)
+		return &buf
+	},
+}
+
+// Returns a pooled buffer of length n.
+func getBuffer(n int) *[]byte {
+	buf := bufferPool.Get().(*[]byte)
+	if cap(*buf) < n {
+		*buf = make([]byte, n)
+	}
+	*buf = (*buf)[:n]
+
+	return buf
+}
+
+func putBuffer(buf *[]byte) {
+	if cap(*buf) <= maxPooledBuffer {
+		bufferPool.Put(buf)
+	}
+}
@@ -0,0 +1,29 @@
+package device
+
+import (
+	"testing"
+)
+
+func BenchmarkReadRegister(b *testing.B) {
+	device, peer := newFakeDevice(b)
+	serveReply(b, peer, []byte{0x12, 0x34})
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := device.ReadRegister(0x10); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadUint16(b *testing.B) {
+	device, peer := newFakeDevice(b)
+	serveReply(b, peer, []byte{0x12, 0x34})
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := device.ReadUint16(0x10); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrNoDevice = errors.New("No device acknowledged the address")
+
+// Present reports whether the device acknowledges its address, probing it
+// with a one byte read the same way i2cdetect -r does.
+func (device *I2C) Present() bool {
+	return device.probe() == nil
+}
+
+// Probes the device once, without retries.
+func (device *I2C) probe() error {
+	return device.attempt(func() error {
+		var buf [1]byte
+		_, err := device.read(buf[:])
+		return err
+	})
+}
+
+// Probes a newly opened device when WithVerifyPresence asked for it.
+func (device *I2C) verifyPresence() error {
+	if device.verify && device.probe() != nil {
+		return fmt.Errorf("%w: 0x%02x", ErrNoDevice, device.addr)
+	}
+
+	return nil
+}
+
+// Waits out an EEPROM style write cycle, during which the device NAKs its
+// address, by probing it until it acknowledges again. This returns as soon as
+// the write is done instead of sleeping for the worst case cycle time.
+// Returns ErrTimeout if the device still doesn't answer after timeout.
+//
+// The probe is a one byte read, which moves an EEPROM's address pointer;
+// that is harmless since the next access sets it again. Probes are spaced
+// writeCyclePollInterval apart so other devices on the bus get a turn.
+func (device *I2C) WriteCyclePoll(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := device.probe()
+		if err == nil {
+			return nil
+		} else if Classify(err) == Fatal {
+			return err
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return ErrTimeout
+		} else if remaining > writeCyclePollInterval {
+			remaining = writeCyclePollInterval
+		}
+		time.Sleep(remaining)
+	}
+}
+
+// Pause between probes in WriteCyclePoll. A typical 5ms write cycle still
+// ends within a probe or so of the device being ready.
+const writeCyclePollInterval = 500 * time.Microsecond
+
+type PresenceEvent string
+
+const (
+	Attached PresenceEvent = "attached"
+	Detached PresenceEvent = "detached"
+)
+
+// PresenceWatcher polls Present and reports when a device comes or goes on a
+// bus where it may be hot plugged. A change is only reported after window
+// consecutive polls agree on it, so momentary glitches don't show up as a
+// detach followed by an attach.
+type PresenceWatcher struct {
+	device   *I2C
+	interval time.Duration
+	window   int
+}
+
+// Poll interval NewPresenceWatcher falls back on when given one that isn't
+// positive.
+const defaultPresenceInterval = time.Second
+
+// Creates a watcher polling device every interval, one second if interval
+// isn't positive, and reporting changes that hold for window polls, at least
+// one.
+func NewPresenceWatcher(device *I2C, interval time.Duration, window int) *PresenceWatcher {
+	if interval <= 0 {
+		interval = defaultPresenceInterval
+	}
+	if window < 1 {
+		window = 1
+	}
+
+	return &PresenceWatcher{device, interval, window}
+}
+
+// Watch starts polling and returns the channel events are sent on. The first
+// event is the device's initial state once it has been stable for a window.
+// Cancelling ctx stops the watcher and closes the channel.
+func (w *PresenceWatcher) Watch(ctx context.Context) <-chan PresenceEvent {
+	events := make(chan PresenceEvent, 1)
+	go func() {
+		defer close(events)
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		var stable, last PresenceEvent
+		run := 0
+		for {
+			current := Detached
+			if w.device.Present() {
+				current = Attached
+			}
+
+			if current == last {
+				run++
+			} else {
+				last, run = current, 1
+			}
+
+			if run >= w.window && current != stable {
+				stable = current
+				select {
+				case events <- stable:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return events
+}
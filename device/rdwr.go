@@ -0,0 +1,205 @@
+package device
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	i2c_RDWR = 0x0707
+	i2c_M_RD = 0x0001
+
+	// Most messages i2c-dev accepts in one I2C_RDWR ioctl.
+	i2cRdwrMaxMsgs = 42
+
+	// Longest write a read-only device sends, a register address of up to
+	// 16 bits ahead of a read.
+	readOnlyMaxAddr = 2
+)
+
+// Mirrors struct i2c_msg from linux/i2c.h.
+type i2cMsg struct {
+	addr  uint16
+	flags uint16
+	len   uint16
+	buf   *byte
+}
+
+// Mirrors struct i2c_rdwr_ioctl_data from linux/i2c-dev.h.
+type i2cRdwrData struct {
+	msgs  *i2cMsg
+	nmsgs uint32
+}
+
+// WriteRead writes w and then reads len(r) bytes into r as one combined
+// transfer, using a repeated start instead of a stop between the two halves.
+// Either half may be empty. The adapter has to support I2C_FUNC_I2C. Neither
+// half may be longer than MaxReadLen.
+//
+// i2c-dev doesn't check the descriptor's mode for combined transfers, so on
+// a read-only device w is only allowed to be a register address of one or
+// two bytes followed by a read, and anything else returns ErrReadOnly.
+func (device *I2C) WriteRead(w []byte, r []byte) error {
+	if err := device.checkWriteRead(w, r); err != nil {
+		return err
+	}
+
+	return device.transact(func() error {
+		return device.writeRead(w, r)
+	})
+}
+
+// Transaction writes w and reads back readLen bytes in one combined transfer.
+// w is sent as is, so it can start with a register address of any width; see
+// TransactionReg16 for devices with 16 bit addresses.
+func (device *I2C) Transaction(w []byte, readLen int) ([]byte, error) {
+	if readLen < 0 {
+		return nil, errors.New("Negative read length")
+	}
+
+	r := make([]byte, readLen)
+	if err := device.WriteRead(w, r); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// TransactionReg16 reads readLen bytes from reg on a device with 16 bit
+// register addresses, such as larger EEPROMs, sending the address in order.
+// Most devices expect it big-endian.
+func (device *I2C) TransactionReg16(reg uint16, readLen int, order binary.ByteOrder) ([]byte, error) {
+	return device.Transaction(reg16Addr(reg, order), readLen)
+}
+
+func reg16Addr(reg uint16, order binary.ByteOrder) []byte {
+	w := make([]byte, 2)
+	order.PutUint16(w, reg)
+	return w
+}
+
+// One message of a Chain. Buf is written to the device, or filled from it
+// when Read is set.
+type Msg struct {
+	Read bool
+	Buf  []byte
+}
+
+// Chain sends msgs as one combined transfer, with a repeated start between
+// each of them and a single stop at the end, for protocols that interleave
+// several writes and reads without releasing the bus. The kernel always ends
+// an I2C_RDWR transfer with a stop, so a chain can't be left open and picked
+// up by a later call: everything that has to happen without a stop goes in
+// one Chain. i2c-dev accepts at most 42 messages, and the adapter has to
+// support I2C_FUNC_I2C.
+//
+// On a read-only device every write has to be a register address followed
+// by a read, as for WriteRead.
+func (device *I2C) Chain(msgs ...Msg) error {
+	if len(msgs) > i2cRdwrMaxMsgs {
+		return fmt.Errorf("Chain of %d messages is longer than the %d i2c-dev allows",
+			len(msgs), i2cRdwrMaxMsgs)
+	}
+
+	for i, msg := range msgs {
+		if msg.Read {
+			continue
+		}
+
+		var next []byte
+		if i+1 < len(msgs) && msgs[i+1].Read {
+			next = msgs[i+1].Buf
+		}
+		if err := device.checkWriteRead(msg.Buf, next); err != nil {
+			return err
+		}
+	}
+
+	return device.transact(func() error {
+		return device.chain(msgs)
+	})
+}
+
+func (device *I2C) chain(msgs []Msg) error {
+	raw, err := device.rdwrMsgs(msgs)
+	if err != nil {
+		return err
+	}
+
+	return device.rdwr(raw)
+}
+
+// Turns msgs into the kernel's messages, leaving out empty ones.
+func (device *I2C) rdwrMsgs(msgs []Msg) ([]i2cMsg, error) {
+	raw := make([]i2cMsg, 0, len(msgs))
+	for _, msg := range msgs {
+		if len(msg.Buf) == 0 {
+			continue
+		}
+		if err := device.checkMsgLen(msg.Buf); err != nil {
+			return nil, err
+		}
+
+		m := i2cMsg{
+			addr: uint16(device.addr),
+			len:  uint16(len(msg.Buf)),
+			buf:  &msg.Buf[0],
+		}
+		if msg.Read {
+			m.flags = i2c_M_RD
+		}
+		raw = append(raw, m)
+	}
+
+	return raw, nil
+}
+
+func (device *I2C) checkWriteRead(w []byte, r []byte) error {
+	if device.readOnly && len(w) > 0 &&
+		(len(r) == 0 || len(w) > readOnlyMaxAddr) {
+		return ErrReadOnly
+	}
+
+	return nil
+}
+
+// i2c-dev rejects messages longer than it reads at once, and the length
+// field of a message only has 16 bits, so long buffers are refused here
+// rather than truncated.
+func (device *I2C) checkMsgLen(buf []byte) error {
+	if limit := device.maxReadLen(); len(buf) > limit {
+		return fmt.Errorf("Message of %d bytes is longer than the %d allowed in one transfer",
+			len(buf), limit)
+	}
+
+	return nil
+}
+
+func (device *I2C) writeRead(w []byte, r []byte) error {
+	return device.chain([]Msg{{Buf: w}, {Read: true, Buf: r}})
+}
+
+func (device *I2C) rdwr(msgs []i2cMsg) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	var start time.Time
+	if device.timing {
+		start = time.Now()
+	}
+
+	data := i2cRdwrData{msgs: &msgs[0], nmsgs: uint32(len(msgs))}
+	_, _, e1 := syscall.Syscall(syscall.SYS_IOCTL, device.rc.Fd(), i2c_RDWR,
+		uintptr(unsafe.Pointer(&data)))
+	var err error
+	if e1 != 0 {
+		err = e1
+	}
+
+	return device.record(msgs[0].flags&i2c_M_RD == 0, start, err)
+}
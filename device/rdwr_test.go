@@ -0,0 +1,86 @@
+package device
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"unsafe"
+)
+
+func msgBytes(m i2cMsg) []byte {
+	return unsafe.Slice(m.buf, m.len)
+}
+
+func TestTransactionAddressWidths(t *testing.T) {
+	tests := []struct {
+		name string
+		w    []byte
+		want []byte
+	}{
+		{"8 bit", []byte{0x10}, []byte{0x10}},
+		{"16 bit big endian", reg16Addr(0x1234, binary.BigEndian), []byte{0x12, 0x34}},
+		{"16 bit little endian", reg16Addr(0x1234, binary.LittleEndian), []byte{0x34, 0x12}},
+	}
+
+	device, _ := newFakeDevice(t)
+	for _, test := range tests {
+		r := make([]byte, 4)
+		msgs, err := device.rdwrMsgs([]Msg{{Buf: test.w}, {Read: true, Buf: r}})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(msgs) != 2 {
+			t.Fatalf("%s: got %d messages, want 2", test.name, len(msgs))
+		}
+
+		write, read := msgs[0], msgs[1]
+		if write.flags != 0 || !bytes.Equal(msgBytes(write), test.want) {
+			t.Errorf("%s: wrote % x with flags %#x, want % x", test.name,
+				msgBytes(write), write.flags, test.want)
+		}
+		if read.flags != i2c_M_RD || read.len != 4 || read.buf != &r[0] {
+			t.Errorf("%s: read message %+v, want 4 bytes into r", test.name, read)
+		}
+		if write.addr != 0x48 || read.addr != 0x48 {
+			t.Errorf("%s: messages address %#x and %#x, want 0x48", test.name,
+				write.addr, read.addr)
+		}
+	}
+}
+
+func TestReadOnlyAddressWidths(t *testing.T) {
+	device, _ := newFakeDevice(t, WithReadOnly())
+	r := make([]byte, 2)
+
+	for _, w := range [][]byte{{0x10}, reg16Addr(0x1234, binary.BigEndian)} {
+		if err := device.checkWriteRead(w, r); err != nil {
+			t.Errorf("Read-only address % x ahead of a read: %v", w, err)
+		}
+		if err := device.checkWriteRead(w, nil); err != ErrReadOnly {
+			t.Errorf("Read-only address % x without a read = %v, want ErrReadOnly", w, err)
+		}
+	}
+
+	if err := device.checkWriteRead([]byte{0x10, 0xFF, 0xFF}, r); err != ErrReadOnly {
+		t.Errorf("Read-only write of data = %v, want ErrReadOnly", err)
+	}
+}
+
+func TestRdwrMsgsTooLong(t *testing.T) {
+	device, _ := newFakeDevice(t)
+	for _, n := range []int{i2cDevMaxRead + 1, 1 << 16} {
+		if _, err := device.rdwrMsgs([]Msg{{Read: true, Buf: make([]byte, n)}}); err == nil {
+			t.Errorf("Message of %d bytes was accepted", n)
+		}
+	}
+}
+
+func TestTransactionNegativeLength(t *testing.T) {
+	device, _ := newFakeDevice(t)
+	if _, err := device.Transaction([]byte{0x10}, -1); err == nil {
+		t.Error("Transaction with a negative length succeeded")
+	}
+	if _, err := device.TransactionReg16(0x1234, -1, binary.BigEndian); err == nil {
+		t.Error("TransactionReg16 with a negative length succeeded")
+	}
+}
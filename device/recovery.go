@@ -0,0 +1,98 @@
+package device
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+var (
+	ErrUnsupported = errors.New("Not supported by this adapter")
+	ErrBusStuck    = errors.New("SDA still held low after bus recovery")
+)
+
+// RecoverBus un-sticks a bus whose SDA line is held low by a device that
+// lost track of a transfer, by clocking SCL nine times and sending a STOP.
+//
+// The kernel's i2c-dev interface doesn't expose the adapter's own recovery
+// to user space, so this only works when a recovery routine was configured
+// with WithBusRecovery, typically RecoveryPins driving the lines through
+// GPIO. Otherwise it returns ErrUnsupported.
+func (device *I2C) RecoverBus() error {
+	return device.attempt(func() error {
+		if device.recoverBus == nil {
+			return ErrUnsupported
+		}
+
+		return device.recoverBus()
+	})
+}
+
+// Runs RecoverBus once the device has failed with EREMOTEIO as many times in
+// a row as WithAutoRecover asked for.
+func (device *I2C) autoRecoverBus() {
+	if device.autoRecover <= 0 {
+		return
+	}
+
+	device.statsMu.Lock()
+	failures := device.remoteIOFailures
+	if failures >= device.autoRecover {
+		device.remoteIOFailures = 0
+	}
+	device.statsMu.Unlock()
+	if failures < device.autoRecover {
+		return
+	}
+
+	log.Printf("i2c device 0x%02x: %d consecutive EREMOTEIO failures, recovering bus",
+		device.addr, failures)
+	if err := device.RecoverBus(); err != nil {
+		log.Printf("i2c device 0x%02x: bus recovery failed: %v", device.addr, err)
+	}
+}
+
+// Direct access to the SCL and SDA lines, for example through GPIO with the
+// pins switched away from the i2c controller.
+type RecoveryPins struct {
+	SetSCL func(high bool)
+	SetSDA func(high bool)
+	GetSDA func() bool
+	// Half of one SCL period, 5 microseconds (100kHz) if zero.
+	HalfPeriod time.Duration
+}
+
+// Recover clocks SCL up to nine times until the device holding SDA lets go,
+// and then sends a STOP. It returns ErrBusStuck if SDA never came back up.
+func (pins RecoveryPins) Recover() error {
+	half := pins.HalfPeriod
+	if half == 0 {
+		half = 5 * time.Microsecond
+	}
+
+	pins.SetSDA(true)
+	pins.SetSCL(true)
+	time.Sleep(half)
+	for i := 0; i < 9 && !pins.GetSDA(); i++ {
+		pins.SetSCL(false)
+		time.Sleep(half)
+		pins.SetSCL(true)
+		time.Sleep(half)
+	}
+	released := pins.GetSDA()
+
+	// STOP: SDA rises while SCL is high.
+	pins.SetSCL(false)
+	pins.SetSDA(false)
+	time.Sleep(half)
+	pins.SetSCL(true)
+	time.Sleep(half)
+	pins.SetSDA(true)
+	time.Sleep(half)
+
+	if !released {
+		return ErrBusStuck
+	}
+
+	return nil
+}
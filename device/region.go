@@ -0,0 +1,91 @@
+package device
+
+import (
+	"errors"
+	"io"
+)
+
+// Number of addressable registers, register numbers are a single byte.
+const registerSpace = 256
+
+// Reads len(expected) bytes starting at startReg and compares them against
+// expected. When they differ the index of the first mismatching byte is
+// returned, otherwise the index is -1.
+func (device *I2C) VerifyRegion(startReg byte, expected []byte) (bool, int, error) {
+	actual := getBuffer(len(expected))
+	defer putBuffer(actual)
+
+	err := device.transact(func() error {
+		_, err := device.sequentialReadInto(startReg, *actual)
+		return err
+	})
+	if err != nil {
+		return false, -1, err
+	}
+
+	for i := range expected {
+		if (*actual)[i] != expected[i] {
+			return false, i, nil
+		}
+	}
+
+	return true, -1, nil
+}
+
+// Reads from reg in one transfer and spreads the bytes across bufs in order,
+// like readv(2). Returns the total number of bytes read; a short read fills
+// the buffers as far as it goes. The i2c-dev driver has no vectored read, so
+// the bytes arrive in a scratch buffer and are copied out from there.
+func (device *I2C) ReadRegisterv(reg byte, bufs ...[]byte) (int, error) {
+	total := 0
+	for _, buf := range bufs {
+		total += len(buf)
+	}
+
+	scratch := getBuffer(total)
+	defer putBuffer(scratch)
+
+	var read int
+	err := device.transact(func() error {
+		var err error
+		read, err = device.readRegisterInto(reg, *scratch, nil)
+		return err
+	})
+
+	remaining := (*scratch)[:read]
+	for _, buf := range bufs {
+		remaining = remaining[copy(buf, remaining):]
+	}
+
+	return read, err
+}
+
+// ReadAt reads len(p) bytes starting at register off, so the register map can
+// be used as an io.ReaderAt. Like SequentialRead it relies on the device
+// auto-incrementing its register pointer across the read. Reads are bounded
+// by the 256 register address space: a read running past its end returns
+// the bytes up to the end along with io.EOF.
+func (device *I2C) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("Negative register offset")
+	} else if off >= registerSpace {
+		return 0, io.EOF
+	}
+
+	n := len(p)
+	if off+int64(n) > registerSpace {
+		n = int(registerSpace - off)
+	}
+
+	var read int
+	err := device.transact(func() error {
+		var err error
+		read, err = device.sequentialReadInto(byte(off), p[:n])
+		return err
+	})
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+
+	return read, err
+}
@@ -0,0 +1,65 @@
+package device
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy decides how often and how quickly a failed transaction is
+// retried. Only Transient errors are retried. The wait before retry n
+// (counting from 0) is BaseDelay*2^n capped at MaxDelay, moved by a random
+// amount of up to Jitter in either direction so that devices failing at the
+// same moment don't all retry in lockstep.
+//
+// A policy is only read, never modified, so one policy can be shared between
+// any number of devices.
+type RetryPolicy struct {
+	// Total number of tries including the first one.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	// Upper bound on the delay before jitter, 0 for no bound.
+	MaxDelay time.Duration
+	Jitter   time.Duration
+}
+
+// Delay returns how long to wait before retry number attempt.
+func (p *RetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 0; i < attempt && delay < math.MaxInt64/2; i++ {
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			break
+		}
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if p.Jitter > 0 {
+		// Keep 2*Jitter+1 from overflowing.
+		maxJitter := p.Jitter
+		if maxJitter > math.MaxInt64/2 {
+			maxJitter = math.MaxInt64 / 2
+		}
+
+		jitter := time.Duration(rand.Int63n(2*int64(maxJitter)+1)) - maxJitter
+		if jitter > 0 && delay > math.MaxInt64-jitter {
+			delay = math.MaxInt64
+		} else {
+			delay += jitter
+		}
+	}
+	if delay < 0 {
+		delay = 0
+	}
+
+	return delay
+}
+
+// Reports whether a transaction that failed with err on the given attempt
+// should be tried again. A nil policy never retries.
+func (p *RetryPolicy) retry(attempt int, err error) bool {
+	return p != nil && err != nil && attempt+1 < p.MaxAttempts &&
+		Classify(err) == Transient
+}
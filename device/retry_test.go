@@ -0,0 +1,77 @@
+package device
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{"first", RetryPolicy{BaseDelay: time.Millisecond}, 0, time.Millisecond},
+		{"doubles", RetryPolicy{BaseDelay: time.Millisecond}, 1, 2 * time.Millisecond},
+		{"grows", RetryPolicy{BaseDelay: time.Millisecond}, 4, 16 * time.Millisecond},
+		{"below cap", RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}, 3, 8 * time.Millisecond},
+		{"capped", RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}, 4, 10 * time.Millisecond},
+		{"capped far out", RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}, 1000, 10 * time.Millisecond},
+		{"zero base", RetryPolicy{}, 10, 0},
+	}
+
+	for _, test := range tests {
+		if got := test.policy.Delay(test.attempt); got != test.want {
+			t.Errorf("%s: Delay(%d) = %v, want %v", test.name, test.attempt, got, test.want)
+		}
+	}
+}
+
+func TestRetryPolicyDelayJitter(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 10 * time.Millisecond, Jitter: 2 * time.Millisecond}
+	for i := 0; i < 1000; i++ {
+		got := policy.Delay(1)
+		if got < 18*time.Millisecond || got > 22*time.Millisecond {
+			t.Fatalf("Delay(1) = %v, want within 20ms ± 2ms", got)
+		}
+	}
+
+	// Jitter larger than the delay never makes it negative.
+	policy = RetryPolicy{BaseDelay: time.Millisecond, Jitter: time.Second}
+	for i := 0; i < 1000; i++ {
+		if got := policy.Delay(0); got < 0 {
+			t.Fatalf("Delay(0) = %v, want >= 0", got)
+		}
+	}
+}
+
+func TestRetryPolicyDelayOverflow(t *testing.T) {
+	policies := []RetryPolicy{
+		{BaseDelay: time.Nanosecond},
+		{BaseDelay: 3 * time.Nanosecond, Jitter: time.Hour},
+		{BaseDelay: time.Duration(math.MaxInt64)},
+		{BaseDelay: time.Duration(math.MaxInt64/2 - 1), Jitter: time.Hour},
+		{BaseDelay: time.Duration(math.MaxInt64/2 - 1), Jitter: time.Duration(math.MaxInt64/2 + 1)},
+		{BaseDelay: time.Duration(math.MaxInt64), Jitter: time.Duration(math.MaxInt64)},
+		{BaseDelay: time.Second, MaxDelay: time.Duration(math.MaxInt64)},
+	}
+
+	for _, policy := range policies {
+		prev := time.Duration(0)
+		for _, attempt := range []int{10, 62, 63, 64, 100, 1 << 20} {
+			got := policy.Delay(attempt)
+			// Jitter of more than a quarter of the range may legitimately
+			// pull a saturated delay that far down.
+			saturated := attempt >= 63 && policy.Jitter < math.MaxInt64/4
+			if got < 0 || saturated && got < math.MaxInt64/4 {
+				t.Errorf("%+v: Delay(%d) = %v, overflowed", policy, attempt, got)
+			}
+			if policy.Jitter == 0 && got < prev {
+				t.Errorf("%+v: Delay(%d) = %v, shorter than %v before it", policy, attempt, got, prev)
+			}
+			prev = got
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package device
+
+import (
+	"errors"
+)
+
+// SMBus Alert Response Address. Devices pulling the shared ALERT line answer
+// a read from it with their own address.
+const alertResponseAddress = 0x0C
+
+var ErrNoAlert = errors.New("No device is raising an SMBus alert")
+
+// Finds out which device on bus number bus raised an SMBus alert by reading
+// the Alert Response Address. The responding device sends its 7 bit address
+// in the upper bits of the byte, which is what is returned. If several
+// devices are alerting the lowest address wins arbitration and the others
+// keep ALERT asserted until they've been read as well. Returns ErrNoAlert
+// when nothing answers.
+//
+// New fails with EBUSY if the kernel's smbus_alert driver has claimed the
+// address.
+func ReadAlertResponse(bus int) (uint8, error) {
+	ara, err := New(alertResponseAddress, bus)
+	if err != nil {
+		return 0, err
+	}
+	defer ara.Close()
+
+	buf := getBuffer(1)
+	defer putBuffer(buf)
+
+	err = ara.attempt(func() error {
+		_, err := ara.read(*buf)
+		return err
+	})
+	if errors.Is(err, ErrAddressNAK) {
+		return 0, ErrNoAlert
+	} else if err != nil {
+		return 0, err
+	}
+
+	return (*buf)[0] >> 1, nil
+}
@@ -0,0 +1,49 @@
+package device
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Reads each of regs as a two byte register in a single transaction, so
+// the device can be put back the way it was with RestoreRegisters.
+func (device *I2C) SnapshotRegisters(regs []byte) (snap map[byte][]byte, err error) {
+	err = device.transact(func() error {
+		snap = make(map[byte][]byte, len(regs))
+		for _, reg := range regs {
+			value, err := device.readRegister(reg, nil)
+			if err != nil {
+				return fmt.Errorf("Reading register 0x%02x: %w", reg, err)
+			}
+			snap[reg] = value
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return snap, nil
+}
+
+// Writes a snapshot taken with SnapshotRegisters back to the device in a
+// single transaction, in ascending register order. Stops at the first
+// register that fails to write.
+func (device *I2C) RestoreRegisters(snap map[byte][]byte) error {
+	regs := make([]int, 0, len(snap))
+	for reg := range snap {
+		regs = append(regs, int(reg))
+	}
+	sort.Ints(regs)
+
+	return device.transactWrite(func() error {
+		for _, reg := range regs {
+			if err := device.writeRegister(byte(reg), snap[byte(reg)]); err != nil {
+				return fmt.Errorf("Restoring register 0x%02x: %w", reg, err)
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,44 @@
+package device
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parses a device spec of the form "bus:addr", such as "1:0x48". The address
+// may be written in hex with a 0x prefix or in decimal; a leading zero
+// doesn't make it octal.
+func ParseSpec(spec string) (bus int, addr uint8, err error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("Invalid device spec %q, expected bus:addr", spec)
+	}
+
+	bus, err = strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || bus < 0 {
+		return 0, 0, fmt.Errorf("Invalid bus %q in device spec %q", parts[0], spec)
+	}
+
+	addrStr, base := strings.TrimSpace(parts[1]), 10
+	if strings.HasPrefix(addrStr, "0x") || strings.HasPrefix(addrStr, "0X") {
+		addrStr, base = addrStr[2:], 16
+	}
+
+	parsedAddr, err := strconv.ParseUint(addrStr, base, 8)
+	if err != nil || parsedAddr > 0x7F {
+		return 0, 0, fmt.Errorf("Invalid address %q in device spec %q", parts[1], spec)
+	}
+
+	return bus, uint8(parsedAddr), nil
+}
+
+// Opens the device described by a "bus:addr" spec, see ParseSpec.
+func OpenSpec(spec string, opts ...Option) (*I2C, error) {
+	bus, addr, err := ParseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(addr, bus, opts...)
+}
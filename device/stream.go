@@ -0,0 +1,42 @@
+package device
+
+import (
+	"context"
+	"time"
+)
+
+// Stream reads reg every interval and hands each sample, or the error from
+// reading it, to fn until ctx is cancelled. Stream blocks and calls fn from
+// the calling goroutine, so start it with go to sample in the background;
+// nothing is left running once it returns. An interval that isn't positive
+// samples back to back.
+func (device *I2C) Stream(ctx context.Context, reg byte, interval time.Duration, fn func([]byte, error)) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for ctx.Err() == nil {
+		var sample []byte
+		err := device.transactContext(ctx, func() error {
+			var err error
+			sample, err = device.readRegister(reg, nil)
+			return err
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		fn(sample, err)
+		if tick == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		}
+	}
+}
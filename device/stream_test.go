@@ -0,0 +1,38 @@
+package device
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestStreamBackToBack(t *testing.T) {
+	device, peer := newFakeDevice(t)
+	samples := [][]byte{{0x00, 0x01}, {0x00, 0x02}, {0x00, 0x03}}
+	for _, sample := range samples {
+		reply(t, peer, sample...)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	got := [][]byte{}
+	device.Stream(ctx, 0x10, 0, func(sample []byte, err error) {
+		if err != nil {
+			t.Errorf("Sample %d: %v", len(got), err)
+		}
+		got = append(got, sample)
+		if len(got) == len(samples) {
+			cancel()
+		}
+	})
+
+	if len(got) != len(samples) {
+		t.Fatalf("Got %d samples, want %d", len(got), len(samples))
+	}
+	for i := range samples {
+		if !bytes.Equal(got[i], samples[i]) {
+			t.Errorf("Sample %d = % x, want % x", i, got[i], samples[i])
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gocql/gocql"
+)
+
+// Moves the database record of a device from oldUUID to newUUID for
+// SwapUUID. The schema is the caller's; since Cassandra can't change a
+// primary key in place, this typically copies every column of the old row
+// into a new one and deletes the old row in a single logged batch.
+type UUIDUpdate func(session *gocql.Session, oldUUID, newUUID gocql.UUID) error
+
+// Replaces the device's UUID with newUUID and has update move its database
+// record over to the new id. The device is written and read back first; if
+// that or the database update fails the old UUID is written back so the
+// device and the database keep agreeing. If restoring fails as well, both
+// errors are reported.
+func (device *I2C) SwapUUID(session *gocql.Session, newUUID [16]byte, update UUIDUpdate) error {
+	if err := device.checkUUID(newUUID); err != nil {
+		return err
+	}
+
+	oldUUID, err := device.UUID()
+	if err != nil {
+		return fmt.Errorf("Reading old UUID: %w", err)
+	}
+
+	err = device.transactWrite(func() error {
+		if err := device.writeUUID(newUUID); err != nil {
+			return err
+		}
+
+		written, err := device.readUUID()
+		if err != nil {
+			return err
+		} else if written != newUUID {
+			return errors.New("UUID read back doesn't match the one written")
+		}
+
+		return nil
+	})
+	if err == nil {
+		if err = update(session, oldUUID, newUUID); err != nil {
+			err = fmt.Errorf("Updating database: %w", err)
+		}
+	}
+	if err == nil {
+		return nil
+	}
+
+	// The old UUID may well be nil, restoring it is fine either way.
+	restoreErr := device.transactWrite(func() error {
+		return device.writeUUID(oldUUID)
+	})
+	if restoreErr != nil {
+		return fmt.Errorf("%w; restoring old UUID: %w", err, restoreErr)
+	}
+
+	return err
+}
+
+// Like UUID, for firmware that reports the length of its identity first. The
+// length is read from the UUID length register, UUIDLengthRegister unless
+// set with WithUUIDLengthRegister, and the UUID is only read when the length
+// is UUIDLength. Otherwise ErrUUIDLengthMismatch is returned.
+func (device *I2C) ReadUUIDChecked() (uuid gocql.UUID, err error) {
+	length := getBuffer(1)
+	defer putBuffer(length)
+
+	err = device.transact(func() error {
+		if err := device.readRegisterFull(device.uuidLenReg, *length, nil); err != nil {
+			return err
+		} else if (*length)[0] != UUIDLength {
+			return ErrUUIDLengthMismatch
+		}
+
+		uuid, err = device.readUUID()
+		return err
+	})
+	return
+}
+
+// Replaces length bytes of the device's UUID starting at offset with data.
+// The UUID protocol has no way of addressing part of the UUID, so this reads
+// the whole UUID, patches it and writes the whole UUID back, all in one
+// transaction. The patched UUID is subject to the same nil check as
+// WriteUUID.
+func (device *I2C) WriteUUIDRange(offset, length int, data []byte) error {
+	if offset < 0 || length < 0 || offset+length > UUIDLength {
+		return errors.New("UUID range out of bounds")
+	} else if len(data) != length {
+		return errors.New("UUID range and data length differ")
+	}
+
+	return device.transactWrite(func() error {
+		uuid, err := device.readUUID()
+		if err != nil {
+			return err
+		}
+
+		copy(uuid[offset:offset+length], data)
+		if err := device.checkUUID(uuid); err != nil {
+			return err
+		}
+
+		return device.writeUUID(uuid)
+	})
+}
@@ -0,0 +1,40 @@
+package device
+
+import (
+	"encoding/binary"
+)
+
+// Firmware version reported by a device. The version register holds two
+// bytes, the first is Major and the second is Minor, so the raw value 0x0102
+// is version 1.2.
+type Version struct {
+	Major uint8
+	Minor uint8
+}
+
+// Compare returns -1 if v is older than other, 1 if it is newer and 0 if they
+// are the same version.
+func (v Version) Compare(other Version) int {
+	switch {
+	case v.Major < other.Major:
+		return -1
+	case v.Major > other.Major:
+		return 1
+	case v.Minor < other.Minor:
+		return -1
+	case v.Minor > other.Minor:
+		return 1
+	}
+
+	return 0
+}
+
+// Reads and decodes the version register of the device.
+func (device *I2C) ReadVersion() (Version, error) {
+	word, err := device.readUint16(VersionRegister, binary.BigEndian)
+	if err != nil {
+		return Version{}, err
+	}
+
+	return Version{Major: uint8(word >> 8), Minor: uint8(word)}, nil
+}